@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xiye518/crawjianshu/internal/transfer"
+)
+
+// 分类模型的训练和评估：
+//
+//	crawjianshu train -data labeled.jsonl -model data/model.json -holdout 0.2
+//	crawjianshu eval -data test.jsonl -model data/model.json
+func runClassifyCommand(cmd string, args []string) {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	data := fs.String("data", "", "标注数据，JSONL 格式，每行含 label、title、abstract、body")
+	model := fs.String("model", "data/model.json", "模型文件")
+	dict := fs.String("dict", "", "train 时使用的分词词典，格式同 jieba 的 dict.txt，为空时用内置词典；词典保存在模型中，eval 和分类时不必再指定")
+	holdout := fs.Float64("holdout", 0.2, "train 时留作测试集的比例，0 表示全部用于训练")
+	fs.Parse(args)
+	if *data == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if cmd == "eval" && *dict != "" {
+		log.Fatal("eval 使用模型中保存的词典，不能再用 -dict 指定")
+	}
+	seg := transfer.NewSegmenter()
+	if *dict != "" {
+		if err := seg.LoadDict(*dict); err != nil {
+			log.Fatal(err)
+		}
+	}
+	samples, err := transfer.LoadLabeled(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var c *transfer.Classifier
+	test := samples
+	if cmd == "train" {
+		train := samples
+		if *holdout > 0 {
+			train, test = transfer.SplitLabeled(samples, *holdout)
+		}
+		if c, err = transfer.TrainClassifier(train, seg); err != nil {
+			log.Fatal(err)
+		}
+		if err := c.Save(*model); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("用 %d 篇文章训练了 %d 个类别，模型已保存到 %s\n", len(train), len(c.Classes), *model)
+		if len(test) == 0 || *holdout <= 0 {
+			return
+		}
+	} else {
+		if c, err = transfer.LoadClassifier(*model); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ev := c.Evaluate(test)
+	fmt.Printf("%-16s %8s %8s %8s %8s\n", "类别", "样本数", "精确率", "召回率", "F1")
+	for _, m := range ev.Classes {
+		fmt.Printf("%-16s %8d %8.3f %8.3f %8.3f\n", m.Label, m.Support, m.Precision, m.Recall, m.F1)
+	}
+	fmt.Printf("共 %d 篇，正确 %d 篇，正确率 %.3f\n", ev.Total, ev.Correct, ev.Accuracy)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/crawl"
+	"github.com/xiye518/crawjianshu/internal/http"
+	"github.com/xiye518/crawjianshu/internal/tools/console/color"
+)
+
+// 从种子地址出发跟随链接抓取：
+//
+//	crawjianshu crawl -depth 2 -max 500 -prefix /p/,/u/ https://www.jianshu.com/
+//	crawjianshu crawl -workers 8 -limit jianshu.com=2,1,500ms -limit upaiyun.com=4,10 https://www.jianshu.com/
+//	crawjianshu crawl -max 0 -state state -bloom 5000000 -bloom-fp 0.0001 https://www.jianshu.com/
+func runCrawlCommand(args []string) {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	depth := fs.Int("depth", 2, "跟随链接的最大深度，0 表示不限制")
+	max := fs.Int("max", 100, "最多抓取的页面数，0 表示不限制")
+	hosts := fs.String("host", "jianshu.com", "允许的主机，逗号分隔，包含子域名")
+	prefixes := fs.String("prefix", "", "允许的路径前缀，逗号分隔")
+	allow := fs.String("allow", "", "地址必须匹配的正则")
+	deny := fs.String("deny", "", "排除的地址正则")
+	workers := fs.Int("workers", 4, "同时抓取的 worker 数")
+	robots := fs.Bool("robots", true, "遵守 robots.txt")
+	report := fs.String("robots-report", "", "把因 robots.txt 跳过的地址写入该文件")
+	state := fs.String("state", "", "保存抓取队列的目录，中断后用同样的参数再次运行即可接着抓")
+	bloom := fs.Int("bloom", 0, "大于 0 时用布隆过滤器记录见过的地址以节省内存，值为预计的地址数，不够时自动扩展")
+	bloomFP := fs.Float64("bloom-fp", 0.001, "布隆过滤器的误判率，误判的地址不会被抓取")
+	cacheDir := fs.String("cache", "", "http 缓存目录")
+	offline := fs.Bool("offline", false, "只从 -cache 指定的缓存读取页面")
+	var limits listFlag
+	fs.Var(&limits, "limit", "按主机限制请求，格式为 主机=并发数,每秒请求数[,随机等待]，可以指定多次，默认 "+defaultLimit)
+	fs.Parse(args)
+	if len(limits) == 0 {
+		limits = listFlag{defaultLimit}
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	scope := crawl.NewScope(splitList(*hosts)...)
+	scope.PathPrefixes = splitList(*prefixes)
+	if *allow != "" {
+		if err := scope.AllowPattern(*allow); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *deny != "" {
+		if err := scope.DenyPattern(*deny); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	httpClient := http.NewClient().DialTimeout(20 * time.Second).Retry(http.DefaultRetryPolicy())
+	useCache(httpClient, *cacheDir, *offline)
+	c := crawl.NewCrawler(httpClient, scope)
+	if *robots {
+		c.Robots = crawl.NewRobotsCache(httpClient, crawl.ProductToken)
+	}
+	var seen crawl.SeenSet
+	if *bloom > 0 {
+		seen = crawl.NewBloomSeen(*bloom, *bloomFP)
+	}
+	c.Frontier = crawl.NewMemFrontier(seen)
+	if *state != "" {
+		f, err := crawl.OpenFrontier(*state, seen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		//队列的每条记录都是直接写入文件的，log.Fatal 跳过 Close 只是少整理一次日志，不会丢失
+		defer f.Close()
+		c.Frontier = f
+	}
+	c.MaxDepth = *depth
+	c.MaxPages = *max
+	c.Workers = *workers
+	c.Limiter = crawl.NewLimiter()
+	for _, l := range limits {
+		hl, err := crawl.ParseHostLimit(l)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.Limiter.Limits = append(c.Limiter.Limits, hl)
+	}
+	i := 0
+	c.OnPage = func(p *crawl.Page) error {
+		if p.Err != nil {
+			color.LogAndPrintln(i, color.HiRed(p.URL), p.Err)
+		} else {
+			color.LogAndPrintln(i, color.HiGreen(p.URL), "深度:", p.Depth, "链接:", len(p.Links))
+		}
+		i++
+		return nil
+	}
+	if err := c.Run(fs.Args()...); err != nil {
+		log.Fatal(err)
+	}
+	st := c.Stats()
+	color.LogAndPrintln("抓取:", st.Fetched, "失败:", st.Failed, "入队:", st.Queued, "范围外:", st.OutOfScope,
+		"robots.txt 禁止:", st.Disallowed)
+	if *report != "" && c.Robots != nil {
+		if err := writeRobotsReport(*report, c.Robots.Skipped()); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// writeRobotsReport 每行一个被跳过的地址和禁止它的规则，以 tab 分隔
+func writeRobotsReport(file string, skipped []*crawl.Skip) error {
+	var sb strings.Builder
+	for _, s := range skipped {
+		sb.WriteString(s.URL + "\t" + s.Rule + "\n")
+	}
+	return ioutil.WriteFile(file, []byte(sb.String()), 0644)
+}
+
+// 简书对频繁的请求很敏感，默认每个主机同时只发 2 个请求，每秒 1 个
+const defaultLimit = "*=2,1,500ms"
+
+// listFlag 是可以指定多次的命令行参数
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *listFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			list = append(list, f)
+		}
+	}
+	return list
+}
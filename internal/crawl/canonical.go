@@ -0,0 +1,256 @@
+package crawl
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// 同一篇文章会以 /p/abc、/p/abc?utm_source=...、https://jianshu.com/p/abc/ 等不同的地址出现。
+// Canonicalizer 把它们规范为同一个地址，抓取队列和存储都以规范地址作为标识，
+// 但抓取时仍然请求原来的地址
+
+// Canonicalizer 规范化地址：scheme 和主机转为小写，去掉默认端口和 #fragment，
+// 去掉跟踪参数，查询参数按名称排序，百分号编码统一为大写，不需要编码的字符解码，再按站点规则调整
+type Canonicalizer struct {
+	// StripParams 要去掉的查询参数，以 * 结尾的表示前缀，如 "utm_*"
+	StripParams []string
+	// Sites 按顺序匹配，第一条匹配的生效
+	Sites []*SiteRule
+}
+
+// SiteRule 是针对某个站点的额外规则
+type SiteRule struct {
+	// Host 匹配的主机，同时匹配其子域名
+	Host string
+	// CanonicalHost 不为空时主机统一改为它，如 jianshu.com 改为 www.jianshu.com
+	CanonicalHost string
+	// ForceHTTPS 把 http 改为 https
+	ForceHTTPS bool
+	// StripTrailingSlash 去掉路径结尾的 /（根路径除外）
+	StripTrailingSlash bool
+	// StripParams 在全局的 StripParams 之外要去掉的参数
+	StripParams []string
+	// KeepParams 不为空时只保留这些参数，其余的都去掉
+	KeepParams []string
+}
+
+// DefaultTrackingParams 是常见的跟踪参数
+var DefaultTrackingParams = []string{
+	"utm_*", "spm", "from", "isappinstalled", "share_token", "fbclid", "gclid", "yclid", "mc_cid", "mc_eid",
+}
+
+// NewCanonicalizer 返回去掉常见跟踪参数、并带有简书规则的 Canonicalizer
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{
+		StripParams: DefaultTrackingParams,
+		Sites: []*SiteRule{{
+			Host:               "jianshu.com",
+			CanonicalHost:      "www.jianshu.com",
+			ForceHTTPS:         true,
+			StripTrailingSlash: true,
+			StripParams:        []string{"utm_*", "u_atoken", "u_asession", "u_asig", "u_aref"},
+		}},
+	}
+}
+
+var defaultCanonicalizer = NewCanonicalizer()
+
+// Canonical 用默认规则规范化地址
+func Canonical(rawurl string) (string, error) {
+	return defaultCanonicalizer.Canonical(rawurl)
+}
+
+// Canonical 规范化一个绝对地址，相对地址原样返回
+func (c *Canonicalizer) Canonical(rawurl string) (string, error) {
+	u, err := http.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() || u.Opaque != "" {
+		return rawurl, nil
+	}
+	return c.CanonicalURL(u), nil
+}
+
+// CanonicalURL 返回 u 的规范形式
+func (c *Canonicalizer) CanonicalURL(u *http.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	h, port := splitPort(strings.ToLower(u.Host))
+	host := strings.TrimSuffix(h, ".")
+	if port != "" && !(scheme == "http" && port == "80" || scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+
+	var site *SiteRule
+	for _, r := range c.Sites {
+		if matchHost(host, []string{r.Host}) {
+			site = r
+			break
+		}
+	}
+
+	path := normalizeEscapes(u.EscapedPath())
+	path = removeDotSegments(path)
+	if path == "" {
+		path = "/"
+	}
+	if site != nil {
+		if site.CanonicalHost != "" {
+			host = site.CanonicalHost
+		}
+		if site.ForceHTTPS && scheme == "http" {
+			scheme = "https"
+		}
+		if site.StripTrailingSlash && len(path) > 1 {
+			path = strings.TrimRight(path, "/")
+			if path == "" {
+				path = "/"
+			}
+		}
+	}
+
+	s := scheme + "://"
+	if u.User != nil {
+		s += u.User.String() + "@"
+	}
+	s += host + path
+	if q := c.query(u, site); q != "" {
+		s += "?" + q
+	}
+	return s
+}
+
+// query 去掉不需要的参数，按名称排序，同名参数保持原来的先后。
+// 每个参数保持原样，只统一百分号编码：没有 = 的参数（如七牛的 ?imageView2/2/w/1240）
+// 不会补上 =，无法解码的参数也原样保留而不是丢掉
+func (c *Canonicalizer) query(u *http.URL, site *SiteRule) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	type param struct{ name, raw string }
+	var params []param
+	for _, raw := range strings.Split(u.RawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		raw = normalizeEscapes(raw)
+		name := raw
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		if key, err := http.QueryUnescape(name); err == nil {
+			name = key
+		}
+		if matchParam(name, c.StripParams) {
+			continue
+		}
+		if site != nil {
+			if matchParam(name, site.StripParams) {
+				continue
+			}
+			if len(site.KeepParams) > 0 && !matchParam(name, site.KeepParams) {
+				continue
+			}
+		}
+		params = append(params, param{name, raw})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.raw
+	}
+	return strings.Join(parts, "&")
+}
+
+// splitPort 把 host 分为主机和端口，没有端口时 port 为空
+func splitPort(host string) (h, port string) {
+	i := strings.LastIndex(host, ":")
+	if i < 0 || i < strings.LastIndex(host, "]") || !strings.HasPrefix(host, "[") && strings.Count(host, ":") > 1 {
+		return host, ""
+	}
+	return host[:i], host[i+1:]
+}
+
+func matchParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(name, p[:len(p)-1]) || name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes 把 %xx 中的十六进制转为大写，不需要编码的字符（字母、数字、-._~）解码
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhexByte(s[i+1])<<4 | unhexByte(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString("%" + strings.ToUpper(s[i+1:i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhexByte(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// removeDotSegments 按 RFC 3986 5.2.4 去掉路径中的 . 和 ..，保留结尾的 /
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	var out []string
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		switch seg {
+		case ".":
+			if i == len(segs)-1 {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if i == len(segs)-1 {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return strings.Join(out, "/")
+}
@@ -0,0 +1,38 @@
+package crawl
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://www.jianshu.com/p/abc", "https://www.jianshu.com/p/abc"},
+		{"https://www.jianshu.com/p/abc?utm_source=desktop&utm_medium=timeline", "https://www.jianshu.com/p/abc"},
+		{"https://jianshu.com/p/abc/", "https://www.jianshu.com/p/abc"},
+		{"HTTP://WWW.JianShu.com:80/p/abc#comments", "https://www.jianshu.com/p/abc"},
+		{"https://www.jianshu.com/search?type=note&q=go&page=2", "https://www.jianshu.com/search?page=2&q=go&type=note"},
+		{"http://example.com:8080/a/./b/../c%7e%2f?b=2&a=1&spm=x", "http://example.com:8080/a/c~%2F?a=1&b=2"},
+		{"https://example.com", "https://example.com/"},
+		{"https://example.com./a/?x=%e4%b8%ad", "https://example.com/a/?x=%E4%B8%AD"},
+		{"/p/abc", "/p/abc"},
+		{"https://upload-images.jianshu.io/a.jpg?imageView2/2/w/1240", "https://upload-images.jianshu.io/a.jpg?imageView2/2/w/1240"},
+		{"https://example.com/a?foo&b=1&a", "https://example.com/a?a&b=1&foo"},
+		{"https://example.com/a?b=%zz&a=%e4%b8%ad+x", "https://example.com/a?a=%E4%B8%AD+x&b=%zz"},
+	}
+	for _, tt := range tests {
+		got, err := Canonical(tt.in)
+		if err != nil {
+			t.Errorf("Canonical(%q): %s", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	c := &Canonicalizer{Sites: []*SiteRule{{Host: "example.com", KeepParams: []string{"id"}}}}
+	got, _ := c.Canonical("https://m.example.com/item?ref=home&id=7")
+	if want := "https://m.example.com/item?id=7"; got != want {
+		t.Errorf("KeepParams: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,285 @@
+package crawl
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// ProductToken 是爬虫的产品名，用于在 robots.txt 中选取组，同时写在 DefaultUserAgent 中，
+// 站长从访问日志里看到的身份与 robots.txt 中针对的身份一致
+const ProductToken = "crawjianshu"
+
+// DefaultUserAgent 是 Crawler 默认的 User-Agent。保留浏览器的部分，简书对非浏览器的 User-Agent 会返回不同的页面
+const DefaultUserAgent = `Mozilla/5.0 (compatible; ` + ProductToken + `/1.0; +https://github.com/xiye518/crawjianshu) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/63.0.3239.132 Safari/537.36`
+
+// Item 是队列中的一个待抓取地址
+type Item struct {
+	// URL 是抓取时请求的地址，保持页面中链接原来的样子
+	URL string `json:"url"`
+	// Key 是去重用的规范地址，为空时用 URL
+	Key     string `json:"key,omitempty"`
+	Depth   int    `json:"depth"` //种子的深度为 0
+	Referer string `json:"referer,omitempty"`
+}
+
+func (it *Item) key() string {
+	if it.Key != "" {
+		return it.Key
+	}
+	return it.URL
+}
+
+// Page 是抓取一个地址的结果
+type Page struct {
+	*Item
+	StatusCode int
+	Header     *http.Header
+	Body       []byte
+	Links      []*http.URL //页面中在范围内的链接，无论是否已经抓过
+	Err        error       //请求失败或状态码不是 2xx
+}
+
+// Stats 是一次抓取的统计
+type Stats struct {
+	Fetched    int //已抓取的页面数，包括失败的
+	Failed     int
+	Disallowed int //因 robots.txt 跳过的地址数
+	Queued     int //入过队的地址数
+	OutOfScope int //因不在范围内被丢弃的链接数
+}
+
+// Crawler 从种子地址出发，按广度优先抓取页面并跟随其中的链接。
+// Workers 个 worker 共用一个 http.Client 同时抓取，所以页面完成的顺序只是大致按层次
+type Crawler struct {
+	Scope *Scope
+	// MaxDepth 跟随链接的最大深度，种子为 0，0 表示不限制
+	MaxDepth int
+	// MaxPages 最多抓取的页面数，0 表示不限制
+	MaxPages int
+	// Workers 同时抓取的 worker 数，默认 1
+	Workers int
+	// Limiter 按主机限制并发和频率，为 nil 时不限制
+	Limiter *Limiter
+	// Robots 不为 nil 时遵守 robots.txt，被禁止的地址不请求，Page.Err 为 ErrDisallowed
+	Robots    *RobotsCache
+	UserAgent string
+	// OnPage 每抓完一个页面（包括失败的）调用一次，返回错误时停止抓取。
+	// OnPage 只在 Run 所在的 goroutine 中调用，不需要加锁
+	OnPage func(p *Page) error
+	// Frontier 保存队列和见过的地址，默认只在内存中；用 OpenFrontier 打开的可以在中断后接着抓
+	Frontier Frontier
+	// Canonicalizer 把地址规范化后再入队，Frontier 以规范地址判断是否见过；为 nil 时只去掉 #fragment
+	Canonicalizer *Canonicalizer
+
+	httpClient *http.Client
+	stats      Stats
+}
+
+func NewCrawler(httpClient *http.Client, scope *Scope) *Crawler {
+	if scope == nil {
+		scope = &Scope{}
+	}
+	return &Crawler{
+		Scope:         scope,
+		UserAgent:     DefaultUserAgent,
+		Frontier:      NewMemFrontier(nil),
+		Canonicalizer: NewCanonicalizer(),
+		httpClient:    httpClient,
+	}
+}
+
+// Stats 返回目前为止的统计
+func (c *Crawler) Stats() Stats {
+	return c.stats
+}
+
+// Run 从 seeds 开始抓取，直到队列为空或达到 MaxPages。
+// Frontier 中已有未完成的地址时从那里接着抓，已经见过的种子不会重复抓取
+func (c *Crawler) Run(seeds ...string) error {
+	for _, s := range seeds {
+		u, err := http.Parse(s)
+		if err != nil {
+			return fmt.Errorf("种子地址 %s: %s", s, err)
+		}
+		if !u.IsAbs() {
+			return fmt.Errorf("种子地址 %s 不是绝对地址", s)
+		}
+		if err := c.enqueue(c.item(u)); err != nil {
+			return err
+		}
+	}
+
+	workers := c.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan *Item)
+	results := make(chan *Page)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range jobs {
+				results <- c.fetch(it)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	//调度只在这个 goroutine 里进行，统计不需要加锁
+	inflight := 0
+	var next *Item //已经从 Frontier 取出、还没交给 worker 的地址
+	for {
+		var send chan *Item
+		if c.MaxPages == 0 || c.stats.Fetched+inflight < c.MaxPages {
+			if next == nil {
+				var err error
+				if next, err = c.Frontier.Next(); err != nil {
+					return err
+				}
+			}
+			if next != nil {
+				send = jobs
+			}
+		}
+		if send == nil && inflight == 0 {
+			break
+		}
+
+		select {
+		case send <- next:
+			next = nil
+			inflight++
+		case p := <-results:
+			inflight--
+			if err := c.done(p); err != nil {
+				//还在进行的请求照常完成，但结果不再处理
+				go func() {
+					for ; inflight > 0; inflight-- {
+						<-results
+					}
+				}()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// done 处理一个抓完的页面：统计、过滤链接并入队，交给 OnPage，最后在 Frontier 中标记完成。
+// OnPage 返回错误时不标记，下次打开 Frontier 时这个地址会重新抓取
+func (c *Crawler) done(p *Page) error {
+	switch {
+	case p.Err == ErrDisallowed:
+		c.stats.Disallowed++
+	case p.Err != nil:
+		c.stats.Fetched++
+		c.stats.Failed++
+	default:
+		c.stats.Fetched++
+	}
+	links := p.Links
+	p.Links = nil
+	for _, u := range links {
+		if ok, _ := c.Scope.Contains(u); !ok {
+			c.stats.OutOfScope++
+			continue
+		}
+		p.Links = append(p.Links, u)
+	}
+	if c.MaxDepth == 0 || p.Depth < c.MaxDepth {
+		for _, u := range p.Links {
+			it := c.item(u)
+			it.Depth, it.Referer = p.Depth+1, p.URL
+			if err := c.enqueue(it); err != nil {
+				return err
+			}
+		}
+	}
+	if c.OnPage != nil {
+		if err := c.OnPage(p); err != nil {
+			return err
+		}
+	}
+	return c.Frontier.Done(p.Item)
+}
+
+func (c *Crawler) enqueue(it *Item) error {
+	ok, err := c.Frontier.Push(it)
+	if ok {
+		c.stats.Queued++
+	}
+	return err
+}
+
+// item 返回抓取 u 的 Item：请求去掉 #fragment 的原地址，用规范地址去重
+func (c *Crawler) item(u *http.URL) *Item {
+	v := *u
+	v.Fragment = ""
+	it := &Item{URL: v.String()}
+	if c.Canonicalizer != nil {
+		if key := c.Canonicalizer.CanonicalURL(u); key != it.URL {
+			it.Key = key
+		}
+	}
+	return it
+}
+
+// fetch 抓取一个地址，html页面会取出其中的全部链接，由 done 按范围过滤。fetch 在 worker 中调用
+func (c *Crawler) fetch(it *Item) *Page {
+	p := &Page{Item: it}
+	u, err := http.Parse(it.URL)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	if c.Robots != nil && !c.Robots.Allowed(u) {
+		p.Err = ErrDisallowed
+		return p
+	}
+	if c.Limiter != nil {
+		defer c.Limiter.Wait(u.Host)()
+	}
+	if c.Robots != nil {
+		c.Robots.Wait(u)
+	}
+	req := http.NewRequest(http.MethodGet, it.URL).
+		SetHeader(`Accept`, `text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8`).
+		SetHeader(`Accept-Encoding`, `gzip, deflate`).
+		SetHeader(`Accept-Language`, `zh-CN,zh;q=0.9`).
+		SetHeader(`User-Agent`, c.UserAgent)
+	if it.Referer != "" {
+		req.SetHeader(`Referer`, it.Referer)
+	}
+	resp, hcerr := req.SendBy(c.httpClient)
+	if hcerr != nil {
+		p.Err = hcerr
+		return p
+	}
+	defer resp.Body.Close()
+
+	p.StatusCode = resp.StatusCode
+	p.Header = resp.Header
+	if p.Body, p.Err = resp.BodyBytes(); p.Err != nil {
+		return p
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.Err = fmt.Errorf("GET %s: %s", it.URL, resp.Status)
+		return p
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if ct != "" && !strings.Contains(ct, "html") {
+		return p
+	}
+	//重定向之后以最终的地址作为补全链接的基准
+	p.Links = ExtractLinks(resp.Request.URL, p.Body)
+	return p
+}
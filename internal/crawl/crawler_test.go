@@ -0,0 +1,88 @@
+package crawl
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// newSite 返回一个测试站点，pages 是路径到页面中链接的映射
+func newSite(pages map[string][]string) *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		links, ok := pages[r.URL.Path]
+		if !ok {
+			nethttp.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>")
+		for _, l := range links {
+			fmt.Fprintf(w, `<a href="%s">x</a>`, l)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+func TestCrawlerBreadthFirst(t *testing.T) {
+	site := newSite(map[string][]string{
+		"/":        {"/p/a", "p/b#comments", "https://other.example.com/x", "/admin", "javascript:void(0)"},
+		"/p/a":     {"/", "../p/c", "/p/a?utm=1"},
+		"/p/b":     {"/p/d"},
+		"/p/c":     {"/p/e"},
+		"/p/d":     nil,
+		"/admin":   nil,
+		"/p/a?x=1": nil,
+	})
+	defer site.Close()
+
+	scope := NewScope("127.0.0.1")
+	if err := scope.DenyPattern(`/admin`); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCrawler(http.NewClient(), scope)
+	c.MaxDepth = 2
+	var got []string
+	c.OnPage = func(p *Page) error {
+		got = append(got, fmt.Sprintf("%d %s", p.Depth, strings.TrimPrefix(p.URL, site.URL)))
+		return nil
+	}
+	if err := c.Run(site.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"0 /", "1 /p/a", "1 /p/b", "2 /p/c", "2 /p/a?utm=1", "2 /p/d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	st := c.Stats()
+	if st.Fetched != 6 || st.OutOfScope != 2 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestCrawlerFetchesOriginalURL(t *testing.T) {
+	var requested []string
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/a.jpg?imageView2/2/w/1240">1</a><a href="/p/a?utm_source=x&id=1">2</a><a href="/p/a?id=1">3</a>`)
+		}
+	}))
+	defer site.Close()
+
+	c := NewCrawler(http.NewClient(), nil)
+	if err := c.Run(site.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	//两个 /p/a 的规范地址相同，只抓第一个，并且请求的是页面中原来的地址
+	want := []string{"/", "/a.jpg?imageView2/2/w/1240", "/p/a?utm_source=x&id=1"}
+	if !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+}
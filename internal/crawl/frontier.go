@@ -0,0 +1,345 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Frontier 保存待抓取的队列和见过的地址。出队的地址在 Done 之前处于进行中，
+// 持久化的 Frontier 重新打开时，进行中的地址会重新入队
+type Frontier interface {
+	// Push 把没见过的地址加入队尾，返回是否入队
+	Push(it *Item) (bool, error)
+	// Next 取出队首的地址，队列为空时返回 nil
+	Next() (*Item, error)
+	// Done 标记地址已处理完，无论成功与否
+	Done(it *Item) error
+	// Len 返回队列中等待的地址数，不含进行中的
+	Len() int
+	Close() error
+}
+
+// memFrontier 是只在内存中的 Frontier
+type memFrontier struct {
+	queue []*Item
+	seen  SeenSet
+}
+
+// NewMemFrontier 返回只在内存中的 Frontier，进程退出后不保留。seen 为 nil 时用 NewMapSeen
+func NewMemFrontier(seen SeenSet) Frontier {
+	if seen == nil {
+		seen = NewMapSeen()
+	}
+	return &memFrontier{seen: seen}
+}
+
+func (f *memFrontier) Push(it *Item) (bool, error) {
+	if !f.seen.Add(it.key()) {
+		return false, nil
+	}
+	f.queue = append(f.queue, it)
+	return true, nil
+}
+
+func (f *memFrontier) Next() (*Item, error) {
+	if len(f.queue) == 0 {
+		return nil, nil
+	}
+	it := f.queue[0]
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+	return it, nil
+}
+
+func (f *memFrontier) Done(it *Item) error { return nil }
+func (f *memFrontier) Len() int            { return len(f.queue) }
+func (f *memFrontier) Close() error        { return nil }
+
+// 磁盘上的 Frontier 是一个只追加的日志 frontier.log，每行一条 json 记录：
+//
+//	{"op":"push","url":"https://jianshu.com/p/abc?utm_source=desktop","key":"https://www.jianshu.com/p/abc","depth":1,"referer":"https://www.jianshu.com/"}
+//	{"op":"done","url":"https://jianshu.com/p/abc?utm_source=desktop","key":"https://www.jianshu.com/p/abc"}
+//
+// 打开时重放日志：push 过的都算见过，push 过但没有 done 的按原来的顺序重新入队。
+// 进程被杀时最后一行可能不完整，重放时忽略。
+//
+// 见过的地址能够自己持久化（如 BloomSeen）时，日志不必保留全部 push 记录：每写 compactEvery 条记录
+// 以及 Close 时，先把见过的地址写入 seen.dat，再把日志改写为只有队列中和进行中的地址。
+// 打开时先读入 seen.dat 再重放日志
+const (
+	frontierLog  = "frontier.log"
+	frontierSeen = "seen.dat"
+	compactEvery = 100000
+)
+
+// persistentSeen 是能够写入文件并读回的 SeenSet
+type persistentSeen interface {
+	SeenSet
+	io.WriterTo
+	io.ReaderFrom
+}
+
+type logRecord struct {
+	Op string `json:"op"`
+	*Item
+}
+
+type diskFrontier struct {
+	mu       sync.Mutex
+	dir      string
+	file     *os.File
+	queue    []*Item
+	inflight []*Item //已经 Next 取出、还没有 Done 的地址，按出队的顺序，整理日志时排在队列前面
+	seen     SeenSet
+	records  int //上次整理之后写入的记录数
+}
+
+// OpenFrontier 打开或创建 dir 下的持久化 Frontier。seen 为 nil 时用 NewMapSeen；
+// 同一个目录每次打开都要用同一种 SeenSet
+func OpenFrontier(dir string, seen SeenSet) (Frontier, error) {
+	if seen == nil {
+		seen = NewMapSeen()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	seenName := filepath.Join(dir, frontierSeen)
+	if ps, ok := seen.(persistentSeen); ok {
+		if err := readSeen(seenName, ps); err != nil {
+			return nil, fmt.Errorf("%s: %s", seenName, err)
+		}
+	} else if _, err := os.Stat(seenName); err == nil {
+		return nil, fmt.Errorf("%s 中保存的见过的地址无法读入 %T", dir, seen)
+	}
+
+	name := filepath.Join(dir, frontierLog)
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f := &diskFrontier{dir: dir, file: file, seen: seen}
+	if err := f.replay(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return f, nil
+}
+
+func (f *diskFrontier) replay() error {
+	var order []*Item
+	pending := make(map[string]*Item)
+	r := bufio.NewReader(f.file)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			//不完整的最后一行截掉，之后的记录从这里接着写
+			if err := f.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var rec logRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Item == nil {
+			return fmt.Errorf("第 %d 字节处的记录无法解析: %s", offset-int64(len(line)), line)
+		}
+		switch rec.Op {
+		case "push":
+			//整理过的日志中的地址已经在 seen.dat 里，所以不看 Add 的结果
+			key := rec.key()
+			f.seen.Add(key)
+			if pending[key] == nil {
+				pending[key] = rec.Item
+				order = append(order, rec.Item)
+			}
+		case "done":
+			delete(pending, rec.key())
+		}
+	}
+	for _, it := range order {
+		if pending[it.key()] != nil {
+			f.queue = append(f.queue, it)
+		}
+	}
+	_, err := f.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// write 追加一条记录。写入失败时截掉写了一半的内容，调用者不应改动内存中的状态，
+// 以保证内存中的队列与日志一致
+func (f *diskFrontier) write(op string, it *Item) error {
+	b, err := json.Marshal(&logRecord{Op: op, Item: it})
+	if err != nil {
+		return err
+	}
+	end, err := f.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = f.file.Write(append(b, '\n')); err != nil {
+		f.file.Truncate(end)
+		return err
+	}
+	f.records++
+	return nil
+}
+
+// maybeCompact 在写入的记录数达到 compactEvery 时整理日志，要在内存中的状态更新之后调用
+func (f *diskFrontier) maybeCompact() error {
+	if f.records < compactEvery {
+		return nil
+	}
+	return f.compact()
+}
+
+// compact 在见过的地址能够持久化时整理日志，否则什么也不做
+func (f *diskFrontier) compact() error {
+	ps, ok := f.seen.(persistentSeen)
+	if !ok {
+		return nil
+	}
+	f.records = 0
+	//先写 seen.dat：在两步之间被杀掉时，旧日志中的地址重放时再加一遍，不会丢失
+	err := writeFileAtomic(filepath.Join(f.dir, frontierSeen), func(w io.Writer) error {
+		_, err := ps.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(f.dir, frontierLog)
+	err = writeFileAtomic(name, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, it := range f.inflight {
+			if err := enc.Encode(&logRecord{Op: "push", Item: it}); err != nil {
+				return err
+			}
+		}
+		for _, it := range f.queue {
+			if err := enc.Encode(&logRecord{Op: "push", Item: it}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.file.Close()
+	f.file, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+// readSeen 读入 seen.dat，文件不存在时什么也不做
+func readSeen(name string, ps persistentSeen) error {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = ps.ReadFrom(file)
+	return err
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再改名，避免留下写了一半的文件
+func writeFileAtomic(name string, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (f *diskFrontier) Push(it *Item) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.seen.Has(it.key()) {
+		return false, nil
+	}
+	if err := f.write("push", it); err != nil {
+		return false, err
+	}
+	f.seen.Add(it.key())
+	f.queue = append(f.queue, it)
+	return true, f.maybeCompact()
+}
+
+func (f *diskFrontier) Next() (*Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queue) == 0 {
+		return nil, nil
+	}
+	it := f.queue[0]
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+	f.inflight = append(f.inflight, it)
+	return it, nil
+}
+
+func (f *diskFrontier) Done(it *Item) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write("done", &Item{URL: it.URL, Key: it.Key}); err != nil {
+		return err
+	}
+	//进行中的地址不超过并发数，逐个查找即可
+	key := it.key()
+	for i, x := range f.inflight {
+		if x.key() == key {
+			f.inflight = append(f.inflight[:i], f.inflight[i+1:]...)
+			break
+		}
+	}
+	return f.maybeCompact()
+}
+
+func (f *diskFrontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue)
+}
+
+func (f *diskFrontier) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.compact(); err != nil {
+		f.file.Close()
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,222 @@
+package crawl
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+func TestFrontierResume(t *testing.T) {
+	site := newSite(map[string][]string{
+		"/":    {"/p/1", "/p/2", "/p/3"},
+		"/p/1": {"/p/4"},
+		"/p/2": nil, "/p/3": nil, "/p/4": nil,
+	})
+	defer site.Close()
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var got []string
+	crawl := func(stopAfter int) error {
+		f, err := OpenFrontier(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		c := NewCrawler(http.NewClient(), nil)
+		c.Frontier = f
+		n := 0
+		c.OnPage = func(p *Page) error {
+			if n++; n == stopAfter {
+				return errors.New("killed")
+			}
+			got = append(got, strings.TrimPrefix(p.URL, site.URL))
+			return nil
+		}
+		return c.Run(site.URL + "/")
+	}
+
+	//第 3 个页面处理时中断，它没有标记完成，恢复后会重新抓取
+	if err := crawl(3); err == nil || err.Error() != "killed" {
+		t.Fatalf("first run: %v", err)
+	}
+	//模拟写到一半被杀掉的记录
+	log, err := os.OpenFile(filepath.Join(dir, frontierLog), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"op":"push","url":"htt`)
+	log.Close()
+
+	if err := crawl(0); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{"/", "/p/1", "/p/2", "/p/3", "/p/4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+
+	f, err := OpenFrontier(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Len() != 0 {
+		t.Errorf("Len = %d after finished crawl, want 0", f.Len())
+	}
+}
+
+func TestFrontierPushWriteError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := OpenFrontier(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//日志写不进去时，内存中的队列和见过的地址都不能变
+	f.(*diskFrontier).file.Close()
+	if ok, err := f.Push(&Item{URL: "/a"}); ok || err == nil {
+		t.Fatalf("Push on a closed log = %v, %v", ok, err)
+	}
+	if f.Len() != 0 || f.(*diskFrontier).seen.Has("/a") {
+		t.Errorf("failed Push changed the in-memory state: Len = %d", f.Len())
+	}
+}
+
+func TestFrontierKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := OpenFrontier(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Push(&Item{URL: "/a?utm_source=x", Key: "/a"})
+	f.Push(&Item{URL: "/b?from=y", Key: "/b"})
+	a, _ := f.Next()
+	f.Done(a)
+	f.Close()
+
+	//重放后按 Key 去重，队列中保留原来的地址
+	f, err = OpenFrontier(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ok, _ := f.Push(&Item{URL: "/a"}); ok {
+		t.Error("/a was queued again under its key")
+	}
+	if it, _ := f.Next(); it == nil || it.URL != "/b?from=y" || it.Key != "/b" {
+		t.Errorf("Next = %+v, want /b?from=y keyed /b", it)
+	}
+	if f.Len() != 0 {
+		t.Errorf("Len = %d, want 0", f.Len())
+	}
+}
+
+func TestFrontierBloomCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := OpenFrontier(dir, NewBloomSeen(1000, 0.001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"/a", "/b", "/c", "/d"} {
+		f.Push(&Item{URL: u})
+	}
+	a, _ := f.Next()
+	f.Done(a)
+	f.Next() //b 进行中
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, frontierLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 3 {
+		t.Errorf("compacted log has %d records, want 3:\n%s", n, b)
+	}
+
+	if _, err := OpenFrontier(dir, nil); err == nil {
+		t.Error("opening a bloom state with a map seen set succeeded")
+	}
+	f, err = OpenFrontier(dir, NewBloomSeen(1000, 0.001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Len() != 3 {
+		t.Errorf("Len = %d after reopen, want 3", f.Len())
+	}
+	if ok, _ := f.Push(&Item{URL: "/a"}); ok {
+		t.Error("finished /a was queued again")
+	}
+	if ok, _ := f.Push(&Item{URL: "/e"}); !ok {
+		t.Error("new /e was not queued")
+	}
+}
+
+func TestFrontierCompactOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frontier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	urls := []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h"}
+	f, err := OpenFrontier(dir, NewBloomSeen(1000, 0.001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range urls {
+		f.Push(&Item{URL: u})
+	}
+	//a 到 f 进行中，其中 c 已完成；整理后进行中的地址仍排在队列前面，按出队的顺序
+	var taken []*Item
+	for range urls[:6] {
+		it, _ := f.Next()
+		taken = append(taken, it)
+	}
+	f.Done(taken[2])
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = OpenFrontier(dir, NewBloomSeen(1000, 0.001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	for {
+		it, err := f.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if it == nil {
+			break
+		}
+		got = append(got, it.URL)
+	}
+	if want := []string{"/a", "/b", "/d", "/e", "/f", "/g", "/h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queue after reopen = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,162 @@
+package crawl
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 抓得太快会被简书封 IP。Limiter 对每个主机分别限制同时进行的请求数和每秒请求数，
+// 每次请求前再随机多等一会儿，避免请求间隔过于整齐
+
+// HostLimit 是一类主机的限制
+type HostLimit struct {
+	// Pattern 主机匹配规则，"jianshu.com" 同时匹配其子域名，"*" 匹配全部主机
+	Pattern string `json:"pattern"`
+	// Concurrency 每个主机同时进行的请求数，0 表示不限制
+	Concurrency int `json:"concurrency"`
+	// RPS 每个主机每秒的请求数，0 表示不限制
+	RPS float64 `json:"rps"`
+	// Burst 令牌桶的容量，即空闲之后允许连续发出的请求数，默认 1
+	Burst int `json:"burst"`
+	// Jitter 每次请求前额外随机等待 0~Jitter
+	Jitter time.Duration `json:"jitter"`
+}
+
+// ParseHostLimit 解析命令行上的限制，格式为 “主机=并发数,每秒请求数[,随机等待]”，
+// 如 "jianshu.com=2,1.5,300ms"
+func ParseHostLimit(s string) (*HostLimit, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return nil, fmt.Errorf("限制 %q 的格式应为 主机=并发数,每秒请求数[,随机等待]", s)
+	}
+	hl := &HostLimit{Pattern: strings.TrimSpace(s[:i])}
+	parts := strings.Split(s[i+1:], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("限制 %q 的格式应为 主机=并发数,每秒请求数[,随机等待]", s)
+	}
+	var err error
+	if hl.Concurrency, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return nil, fmt.Errorf("限制 %q: 并发数 %s", s, err)
+	}
+	if hl.RPS, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+		return nil, fmt.Errorf("限制 %q: 每秒请求数 %s", s, err)
+	}
+	if len(parts) == 3 {
+		if hl.Jitter, err = time.ParseDuration(strings.TrimSpace(parts[2])); err != nil {
+			return nil, fmt.Errorf("限制 %q: 随机等待 %s", s, err)
+		}
+	}
+	return hl, nil
+}
+
+func (hl *HostLimit) match(host string) bool {
+	return hl.Pattern == "*" || matchHost(host, []string{strings.TrimPrefix(hl.Pattern, "*.")})
+}
+
+// Limiter 按主机限制请求，可以被多个 worker 同时使用
+type Limiter struct {
+	// Limits 按顺序匹配，第一条匹配的规则生效，都不匹配的主机不受限制
+	Limits []*HostLimit
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	limit  *HostLimit
+	sem    chan struct{}
+	bucket *tokenBucket
+}
+
+func NewLimiter(limits ...*HostLimit) *Limiter {
+	return &Limiter{Limits: limits, hosts: make(map[string]*hostState)}
+}
+
+// Wait 等到可以向 host 发出请求为止，请求结束后必须调用返回的 release
+func (l *Limiter) Wait(host string) (release func()) {
+	st := l.state(hostname(strings.ToLower(host)))
+	if st.limit == nil {
+		return func() {}
+	}
+	if st.sem != nil {
+		st.sem <- struct{}{}
+	}
+	if st.bucket != nil {
+		if d := st.bucket.reserve(time.Now()); d > 0 {
+			time.Sleep(d)
+		}
+	}
+	if st.limit.Jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(st.limit.Jitter))))
+	}
+	return func() {
+		if st.sem != nil {
+			<-st.sem
+		}
+	}
+}
+
+func (l *Limiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hosts == nil {
+		l.hosts = make(map[string]*hostState)
+	}
+	if st, ok := l.hosts[host]; ok {
+		return st
+	}
+	st := &hostState{}
+	for _, hl := range l.Limits {
+		if !hl.match(host) {
+			continue
+		}
+		st.limit = hl
+		if hl.Concurrency > 0 {
+			st.sem = make(chan struct{}, hl.Concurrency)
+		}
+		if hl.RPS > 0 {
+			st.bucket = newTokenBucket(hl.RPS, hl.Burst)
+		}
+		break
+	}
+	l.hosts[host] = st
+	return st
+}
+
+// tokenBucket 以 rate 个每秒的速度补充令牌，最多存 burst 个。
+// 令牌不够时预支，返回需要等待的时间，这样等待的请求按到达顺序依次放行
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
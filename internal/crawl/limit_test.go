@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+func TestParseHostLimit(t *testing.T) {
+	hl, err := ParseHostLimit("jianshu.com=2,1.5,300ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hl.Pattern != "jianshu.com" || hl.Concurrency != 2 || hl.RPS != 1.5 || hl.Jitter != 300*time.Millisecond {
+		t.Errorf("ParseHostLimit = %+v", hl)
+	}
+	if !hl.match("www.jianshu.com:443") || hl.match("notjianshu.com") {
+		t.Error("pattern jianshu.com should match subdomains only")
+	}
+	if _, err := ParseHostLimit("jianshu.com=2"); err == nil {
+		t.Error("expected error for missing rps")
+	}
+}
+
+func TestCrawlerWorkersRespectLimit(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < 12; i++ {
+				fmt.Fprintf(w, `<a href="/p/%d">%d</a>`, i, i)
+			}
+		}
+	}))
+	defer site.Close()
+
+	c := NewCrawler(http.NewClient(), nil)
+	c.Workers = 6
+	c.Limiter = NewLimiter(&HostLimit{Pattern: "*", Concurrency: 2, RPS: 100, Burst: 2})
+	start := time.Now()
+	if err := c.Run(site.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Stats(); st.Fetched != 13 || st.Failed != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak)
+	}
+	//13 个请求、令牌桶容量 2，至少要等 11 个 10ms
+	if d := time.Since(start); d < 110*time.Millisecond {
+		t.Errorf("crawl took %s, rate limit not applied", d)
+	}
+}
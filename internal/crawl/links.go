@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ExtractLinks 取出html中 a、area、link[rel=next|prev]、iframe 的链接，
+// 按页面中的 <base href> 或 base 补全为绝对地址，去掉 #fragment，重复的只返回一次
+func ExtractLinks(base *http.URL, body []byte) []*http.URL {
+	var links []*http.URL
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return
+		}
+		switch scheme := strings.ToLower(strings.SplitN(ref, ":", 2)[0]); scheme {
+		case "javascript", "mailto", "tel", "data":
+			return
+		}
+		u, err := http.Parse(ref)
+		if err != nil {
+			return
+		}
+		u = base.ResolveReference(u)
+		u.Fragment = ""
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, u)
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		switch tok.DataAtom {
+		case atom.Base:
+			//只有出现在链接之前的 <base> 才有意义，简书的页面都在 head 里
+			if href := tokenAttr(tok, "href"); href != "" && len(links) == 0 {
+				if u, err := http.Parse(href); err == nil {
+					base = base.ResolveReference(u)
+				}
+			}
+		case atom.A, atom.Area:
+			if !hasToken(tokenAttr(tok, "rel"), "nofollow") {
+				add(tokenAttr(tok, "href"))
+			}
+		case atom.Link:
+			if rel := tokenAttr(tok, "rel"); hasToken(rel, "next") || hasToken(rel, "prev") || hasToken(rel, "canonical") {
+				add(tokenAttr(tok, "href"))
+			}
+		case atom.Iframe, atom.Frame:
+			add(tokenAttr(tok, "src"))
+		}
+	}
+}
+
+func tokenAttr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasToken 判断以空格分隔的属性值中是否有 t，不区分大小写
+func hasToken(v, t string) bool {
+	for _, f := range strings.Fields(v) {
+		if strings.EqualFold(f, t) {
+			return true
+		}
+	}
+	return false
+}
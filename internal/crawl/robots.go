@@ -0,0 +1,318 @@
+package crawl
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// robots.txt 的解析按 RFC 9309：选取与 User-Agent 最匹配的组，组内最长的规则生效，
+// Allow 与 Disallow 一样长时 Allow 优先；* 匹配任意字符，$ 匹配地址结尾
+
+// ErrDisallowed 表示地址被 robots.txt 禁止抓取
+var ErrDisallowed = errors.New("robots.txt 禁止抓取")
+
+// Robots 是解析后的 robots.txt
+type Robots struct {
+	groups      []*robotsGroup
+	unavailable string //robots.txt 无法获取的原因，此时全部禁止
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// String 返回规则在 robots.txt 中的写法
+func (r robotsRule) String() string {
+	if r.allow {
+		return "Allow: " + r.pattern
+	}
+	return "Disallow: " + r.pattern
+}
+
+// ParseRobots 解析 robots.txt 的内容，无法识别的行忽略
+func ParseRobots(body []byte) *Robots {
+	rb := &Robots{}
+	var g *robotsGroup
+	inAgents := false //连续的 User-agent 行属于同一组
+	sc := bufio.NewScanner(strings.NewReader(string(body)))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				g = &robotsGroup{}
+				rb.groups = append(rb.groups, g)
+			}
+			g.agents = append(g.agents, strings.ToLower(val))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			//空的 Disallow 表示不限制，不需要记录
+			if g != nil && val != "" {
+				g.rules = append(g.rules, robotsRule{allow: key == "allow", pattern: val})
+			}
+		case "crawl-delay":
+			if g != nil {
+				if f, err := strconv.ParseFloat(val, 64); err == nil && f > 0 {
+					g.crawlDelay = time.Duration(f * float64(time.Second))
+				}
+			}
+		}
+		inAgents = false
+	}
+	return rb
+}
+
+// group 按 RFC 9309 选取组：agent 与 userAgent 的产品名不区分大小写地相等，都不匹配时取 *。
+// 只比较整个产品名，User-agent: jianshu 的组不会用于 crawjianshu
+func (rb *Robots) group(userAgent string) *robotsGroup {
+	token := productToken(userAgent)
+	var star *robotsGroup
+	for _, g := range rb.groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if star == nil {
+					star = g
+				}
+			} else if productToken(a) == token {
+				return g
+			}
+		}
+	}
+	return star
+}
+
+// productToken 取出 "crawjianshu/1.0" 中的产品名并转为小写
+func productToken(ua string) string {
+	ua = strings.TrimSpace(ua)
+	if i := strings.IndexAny(ua, "/ "); i >= 0 {
+		ua = ua[:i]
+	}
+	return strings.ToLower(ua)
+}
+
+// Test 判断 userAgent 能否抓取 path（含查询参数），不能时同时返回禁止它的规则
+func (rb *Robots) Test(userAgent, path string) (bool, string) {
+	if rb.unavailable != "" {
+		return false, rb.unavailable
+	}
+	g := rb.group(userAgent)
+	if g == nil {
+		return true, ""
+	}
+	var match *robotsRule
+	for i, r := range g.rules {
+		if !robotsMatch(r.pattern, path) {
+			continue
+		}
+		if match == nil || len(r.pattern) > len(match.pattern) ||
+			len(r.pattern) == len(match.pattern) && r.allow && !match.allow {
+			match = &g.rules[i]
+		}
+	}
+	if match == nil || match.allow {
+		return true, ""
+	}
+	return false, match.String()
+}
+
+// CrawlDelay 返回 userAgent 对应组的 Crawl-delay，没有时为 0
+func (rb *Robots) CrawlDelay(userAgent string) time.Duration {
+	if g := rb.group(userAgent); g != nil {
+		return g.crawlDelay
+	}
+	return 0
+}
+
+// robotsMatch 判断 path 是否以 pattern 开头，pattern 中 * 匹配任意字符，结尾的 $ 匹配地址结尾
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, p := range parts[1:] {
+		//最后一段在锚定时必须出现在结尾，否则取最靠前的位置
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, p)
+		}
+		j := strings.Index(rest, p)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(p):]
+	}
+	return !anchored || rest == ""
+}
+
+// RobotsCache 按主机获取并缓存 robots.txt，并记下因 robots.txt 被跳过的地址
+type RobotsCache struct {
+	// UserAgent 用于选取 robots.txt 中的组，是产品名，如 ProductToken
+	UserAgent string
+	// TTL 缓存的有效期，默认 24 小时
+	TTL time.Duration
+	// RetryAfter robots.txt 获取失败时，过多久重新获取，默认 10 分钟
+	RetryAfter time.Duration
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	hosts      map[string]*robotsEntry
+	skipped    []*Skip
+}
+
+type robotsEntry struct {
+	once    sync.Once
+	robots  *Robots
+	fetched time.Time
+	next    time.Time //按 Crawl-delay 下一次可以请求的时间
+}
+
+// Skip 是一个因 robots.txt 被跳过的地址
+type Skip struct {
+	URL  string
+	Rule string //禁止它的规则，robots.txt 无法获取时为说明
+}
+
+func NewRobotsCache(httpClient *http.Client, userAgent string) *RobotsCache {
+	return &RobotsCache{
+		UserAgent:  userAgent,
+		TTL:        24 * time.Hour,
+		RetryAfter: 10 * time.Minute,
+		httpClient: httpClient,
+		hosts:      make(map[string]*robotsEntry),
+	}
+}
+
+// Allowed 判断能否抓取 u，不能时把 u 记入 Skipped
+func (rc *RobotsCache) Allowed(u *http.URL) bool {
+	rb := rc.robots(u)
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	ok, rule := rb.Test(rc.UserAgent, path)
+	if !ok {
+		rc.mu.Lock()
+		rc.skipped = append(rc.skipped, &Skip{URL: u.String(), Rule: rule})
+		rc.mu.Unlock()
+	}
+	return ok
+}
+
+// Wait 按 u 所在主机的 Crawl-delay 等待，没有 Crawl-delay 时立即返回
+func (rc *RobotsCache) Wait(u *http.URL) {
+	delay := rc.robots(u).CrawlDelay(rc.UserAgent)
+	if delay <= 0 {
+		return
+	}
+	e := rc.entry(u)
+	rc.mu.Lock()
+	now := time.Now()
+	at := e.next
+	if at.Before(now) {
+		at = now
+	}
+	e.next = at.Add(delay)
+	rc.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
+
+// CheckRequest 可以设为 http.Client 的 CheckRequest，这样经过该 Client 的每个请求（不只是 Crawler 的）
+// 都遵守 robots.txt：被禁止的返回 ErrDisallowed 并记入 Skipped，允许的按 Crawl-delay 等待。
+// 获取 robots.txt 本身的请求不检查。Crawler 自己会检查，它的 Client 不需要再设置
+func (rc *RobotsCache) CheckRequest(req *http.Request) error {
+	if req.URL.Path == "/robots.txt" {
+		return nil
+	}
+	if !rc.Allowed(req.URL) {
+		return ErrDisallowed
+	}
+	rc.Wait(req.URL)
+	return nil
+}
+
+// Skipped 返回目前为止因 robots.txt 被跳过的地址
+func (rc *RobotsCache) Skipped() []*Skip {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]*Skip(nil), rc.skipped...)
+}
+
+func (rc *RobotsCache) entry(u *http.URL) *robotsEntry {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e := rc.hosts[key]
+	ttl := rc.TTL
+	if e != nil && e.robots != nil && e.robots.unavailable != "" {
+		ttl = rc.RetryAfter
+	}
+	if e == nil || ttl > 0 && !e.fetched.IsZero() && time.Since(e.fetched) > ttl {
+		e = &robotsEntry{}
+		rc.hosts[key] = e
+	}
+	return e
+}
+
+// robots 返回 u 所在主机的 robots.txt，同一主机只请求一次
+func (rc *RobotsCache) robots(u *http.URL) *Robots {
+	e := rc.entry(u)
+	e.once.Do(func() {
+		rb := rc.fetch(u.Scheme + "://" + u.Host + "/robots.txt")
+		rc.mu.Lock()
+		e.robots, e.fetched = rb, time.Now()
+		rc.mu.Unlock()
+	})
+	return e.robots
+}
+
+// fetch 获取 robots.txt。按 RFC 9309，4xx 视为没有限制；5xx 或网络错误时无法确定，视为全部禁止
+func (rc *RobotsCache) fetch(url string) *Robots {
+	resp, hcerr := http.NewRequest(http.MethodGet, url).
+		SetHeader(`User-Agent`, DefaultUserAgent).
+		SendBy(rc.httpClient)
+	if hcerr != nil {
+		return &Robots{unavailable: "获取 robots.txt 失败: " + hcerr.Error()}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		b, err := resp.BodyBytes()
+		if err != nil {
+			return &Robots{unavailable: "读取 robots.txt 失败: " + err.Error()}
+		}
+		return ParseRobots(b)
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return &Robots{}
+	}
+	return &Robots{unavailable: "获取 robots.txt 失败: " + resp.Status}
+}
@@ -0,0 +1,124 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+const testRobots = `
+# 注释
+User-agent: Googlebot
+Disallow: /
+
+User-agent: JianShu
+Disallow: /
+
+User-agent: *
+User-agent: crawjianshu
+Disallow: /search
+Disallow: /*/comments$
+Allow: /search/about
+Disallow: /*.json
+Crawl-delay: 0.5
+`
+
+func TestRobotsTest(t *testing.T) {
+	rb := ParseRobots([]byte(testRobots))
+	tests := []struct {
+		ua, path string
+		want     bool
+	}{
+		{"crawjianshu/1.0", "/p/abc", true},
+		{"crawjianshu/1.0", "/search?q=go", false},
+		{"crawjianshu/1.0", "/search/about", true},
+		{"crawjianshu/1.0", "/p/abc/comments", false},
+		{"crawjianshu/1.0", "/p/abc/comments/1", true},
+		{"crawjianshu/1.0", "/notes/1.json?page=2", false},
+		{"CrawJianShu", "/search?q=go", false},
+		{"Googlebot/2.1", "/p/abc", false},
+		//只匹配完整的产品名：jianshu 的组不适用于 crawjianshu，反之亦然
+		{"jianshu/2.0", "/p/abc", false},
+		{"otherbot", "/search/about", true},
+	}
+	for _, tt := range tests {
+		if got, rule := rb.Test(tt.ua, tt.path); got != tt.want {
+			t.Errorf("Test(%q, %q) = %v (%s), want %v", tt.ua, tt.path, got, rule, tt.want)
+		}
+	}
+	if d := rb.CrawlDelay("crawjianshu"); d != 500*time.Millisecond {
+		t.Errorf("CrawlDelay = %s, want 500ms", d)
+	}
+	rb = ParseRobots([]byte("User-agent: jianshu\nDisallow: /\n"))
+	if ok, rule := rb.Test(ProductToken, "/p/abc"); !ok {
+		t.Errorf("group for jianshu applied to %s: %s", ProductToken, rule)
+	}
+}
+
+func TestCrawlerHonoursRobots(t *testing.T) {
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/p/1">1</a><a href="/private/2">2</a>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer site.Close()
+
+	client := http.NewClient()
+	c := NewCrawler(client, nil)
+	c.Robots = NewRobotsCache(client, "crawjianshu")
+	if err := c.Run(site.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Stats(); st.Fetched != 2 || st.Disallowed != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+	skipped := c.Robots.Skipped()
+	if len(skipped) != 1 || skipped[0].URL != site.URL+"/private/2" || skipped[0].Rule != "Disallow: /private" {
+		t.Errorf("skipped = %+v", skipped)
+	}
+}
+
+func TestRobotsCheckRequest(t *testing.T) {
+	var requested []string
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requested = append(requested, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /search\n")
+		}
+	}))
+	defer site.Close()
+
+	//不经过 Crawler 的请求（如搜索的 POST）也要检查
+	client := http.NewClient()
+	rc := NewRobotsCache(client, ProductToken)
+	client.CheckRequest = rc.CheckRequest
+	_, hcerr := http.NewRequest(http.MethodPost, site.URL+"/search/do").SetBody("q=go").SendBy(client)
+	if hcerr == nil || !errors.Is(hcerr, ErrDisallowed) {
+		t.Errorf("POST /search/do: err = %v, want ErrDisallowed", hcerr)
+	}
+	resp, hcerr := http.NewRequest(http.MethodGet, site.URL+"/p/1").SendBy(client)
+	if hcerr != nil {
+		t.Fatal(hcerr)
+	}
+	resp.Body.Close()
+
+	if want := []string{"GET /robots.txt", "GET /p/1"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+	skipped := rc.Skipped()
+	if len(skipped) != 1 || skipped[0].URL != site.URL+"/search/do" {
+		t.Errorf("skipped = %+v", skipped)
+	}
+}
@@ -0,0 +1,107 @@
+package crawl
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// Scope 决定哪些链接可以入队。各条规则同时生效：
+// 主机和路径前缀为空时不限制；命中 Deny 的一律排除；Allow 不为空时必须命中其中之一
+type Scope struct {
+	// Hosts 允许的主机，"jianshu.com" 同时匹配其子域名，如 www.jianshu.com
+	Hosts []string
+	// PathPrefixes 允许的路径前缀，如 "/p/"、"/u/"
+	PathPrefixes []string
+	// Allow、Deny 对完整地址做匹配的正则
+	Allow []*regexp.Regexp
+	Deny  []*regexp.Regexp
+}
+
+// NewScope 返回只限制主机的 Scope
+func NewScope(hosts ...string) *Scope {
+	return &Scope{Hosts: hosts}
+}
+
+// AllowPattern 加入一条允许的正则
+func (s *Scope) AllowPattern(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	s.Allow = append(s.Allow, re)
+	return nil
+}
+
+// DenyPattern 加入一条排除的正则
+func (s *Scope) DenyPattern(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	s.Deny = append(s.Deny, re)
+	return nil
+}
+
+// Contains 判断 u 是否在范围内，不在时返回原因
+func (s *Scope) Contains(u *http.URL) (bool, string) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false, "scheme " + u.Scheme
+	}
+	if len(s.Hosts) > 0 && !matchHost(u.Host, s.Hosts) {
+		return false, "host " + u.Host
+	}
+	if len(s.PathPrefixes) > 0 {
+		ok := false
+		for _, p := range s.PathPrefixes {
+			if strings.HasPrefix(u.Path, p) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false, "path " + u.Path
+		}
+	}
+	str := u.String()
+	for _, re := range s.Deny {
+		if re.MatchString(str) {
+			return false, "deny " + re.String()
+		}
+	}
+	if len(s.Allow) > 0 {
+		for _, re := range s.Allow {
+			if re.MatchString(str) {
+				return true, ""
+			}
+		}
+		return false, "not allowed"
+	}
+	return true, ""
+}
+
+// matchHost 判断 host（可以带端口）是否为 hosts 之一或其子域名
+func matchHost(host string, hosts []string) bool {
+	host = strings.ToLower(hostname(host))
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname 去掉 host 中的端口
+func hostname(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if i := strings.Index(host, "]"); i >= 0 {
+			return host[1:i]
+		}
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 && strings.Count(host, ":") == 1 {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,289 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// SeenSet 记录见过的地址。抓取几百万个地址时 map 会占用大量内存，
+// 这时可以换成 BloomSeen，代价是有很小的概率把没见过的地址当作见过而漏抓
+type SeenSet interface {
+	// Add 加入一个地址，返回之前是否没见过
+	Add(key string) bool
+	Has(key string) bool
+	// Len 返回加入过的地址数，BloomSeen 中误判为见过的不计入
+	Len() int
+}
+
+type mapSeen map[string]struct{}
+
+// NewMapSeen 返回用 map 实现的 SeenSet，不会误判，适合规模不大的抓取
+func NewMapSeen() SeenSet {
+	return make(mapSeen)
+}
+
+func (s mapSeen) Add(key string) bool {
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+func (s mapSeen) Has(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+func (s mapSeen) Len() int { return len(s) }
+
+// BloomSeen 是可扩展的布隆过滤器（Almeida 等，Scalable Bloom Filters）。
+// 由若干层组成，当前层装满后追加一层容量翻倍、误判率减半的新层，
+// 所以无论加入多少地址，总的误判率都不超过 FPRate
+type BloomSeen struct {
+	// FPRate 总的误判率
+	FPRate float64
+	layers []*bloomLayer
+	count  int
+}
+
+type bloomLayer struct {
+	capacity int //装满的地址数
+	count    int //已加入的地址数
+	k        int //哈希函数个数
+	bits     []uint64
+}
+
+// 每层容量是上一层的 bloomGrowth 倍，误判率是上一层的 bloomTightening 倍
+const (
+	bloomGrowth     = 2
+	bloomTightening = 0.5
+)
+
+// NewBloomSeen 返回第一层能装 capacity 个地址、总误判率为 fpRate 的 BloomSeen
+func NewBloomSeen(capacity int, fpRate float64) *BloomSeen {
+	if capacity < 1024 {
+		capacity = 1024
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	b := &BloomSeen{FPRate: fpRate}
+	b.layers = []*bloomLayer{newBloomLayer(capacity, layerFPRate(fpRate, 0))}
+	return b
+}
+
+// layerFPRate 返回总误判率为 fp 时第 i 层的误判率
+func layerFPRate(fp float64, i int) float64 {
+	return fp * (1 - bloomTightening) * math.Pow(bloomTightening, float64(i))
+}
+
+// bloomSize 按容量 n 和误判率 p 计算位数 m = -n·ln p / (ln 2)² 换算成的 uint64 个数，和哈希函数个数 k = log2(1/p)
+func bloomSize(n int, p float64) (words, k int) {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k = int(math.Ceil(-math.Log2(p)))
+	if k < 1 {
+		k = 1
+	}
+	return int((m + 63) / 64), k
+}
+
+func newBloomLayer(n int, p float64) *bloomLayer {
+	words, k := bloomSize(n, p)
+	return &bloomLayer{capacity: n, k: k, bits: make([]uint64, words)}
+}
+
+// bloomHash 用 128 位 FNV-1a 的两半做双重哈希，第 i 个位置为 h1 + i·h2
+func bloomHash(key string) (h1, h2 uint64) {
+	h := fnv.New128a()
+	io.WriteString(h, key)
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (l *bloomLayer) has(h1, h2 uint64) bool {
+	m := uint64(len(l.bits)) * 64
+	for i := 0; i < l.k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		if l.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *bloomLayer) add(h1, h2 uint64) {
+	m := uint64(len(l.bits)) * 64
+	for i := 0; i < l.k; i++ {
+		pos := (h1 + uint64(i)*h2) % m
+		l.bits[pos/64] |= 1 << (pos % 64)
+	}
+	l.count++
+}
+
+func (b *BloomSeen) has(h1, h2 uint64) bool {
+	for _, l := range b.layers {
+		if l.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BloomSeen) Has(key string) bool {
+	return b.has(bloomHash(key))
+}
+
+func (b *BloomSeen) Add(key string) bool {
+	h1, h2 := bloomHash(key)
+	if b.has(h1, h2) {
+		return false
+	}
+	last := b.layers[len(b.layers)-1]
+	if last.count >= last.capacity {
+		last = newBloomLayer(last.capacity*bloomGrowth, layerFPRate(b.FPRate, len(b.layers)))
+		b.layers = append(b.layers, last)
+	}
+	last.add(h1, h2)
+	b.count++
+	return true
+}
+
+func (b *BloomSeen) Len() int { return b.count }
+
+// Size 返回占用的内存字节数
+func (b *BloomSeen) Size() int {
+	n := 0
+	for _, l := range b.layers {
+		n += len(l.bits) * 8
+	}
+	return n
+}
+
+// 持久化的格式：魔数、版本，FPRate、层数，每层的 capacity、count、k、位数组的长度和内容，都是大端序
+const (
+	bloomMagic   = "CJSB"
+	bloomVersion = 1
+)
+
+var errBadBloom = errors.New("不是有效的 BloomSeen 文件")
+
+// WriteTo 把 b 写入 w，用 ReadFrom 读回
+func (b *BloomSeen) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	cw.write([]byte(bloomMagic))
+	cw.write(uint32(bloomVersion), math.Float64bits(b.FPRate), uint32(len(b.layers)))
+	for _, l := range b.layers {
+		cw.write(uint64(l.capacity), uint64(l.count), uint32(l.k), uint64(len(l.bits)), l.bits)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ReadFrom 从 r 读入 WriteTo 写出的内容，替换 b 原有的内容
+func (b *BloomSeen) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(bloomMagic))
+	var version, nlayers uint32
+	var fp uint64
+	cr.read(magic, &version, &fp, &nlayers)
+	if cr.err != nil {
+		return cr.n, cr.err
+	}
+	fpRate := math.Float64frombits(fp)
+	if string(magic) != bloomMagic || version != bloomVersion || nlayers == 0 || nlayers > 64 || !(fpRate > 0 && fpRate < 1) {
+		return cr.n, errBadBloom
+	}
+	var layers []*bloomLayer
+	count := 0
+	for i := uint32(0); i < nlayers && cr.err == nil; i++ {
+		var capacity, n, words uint64
+		var k uint32
+		cr.read(&capacity, &n, &k, &words)
+		if cr.err != nil {
+			break
+		}
+		//层的大小由容量和误判率决定，和记录的不一致说明文件损坏，不能照着它分配内存
+		if capacity == 0 || capacity > math.MaxInt32 || n > capacity {
+			return cr.n, errBadBloom
+		}
+		wantWords, wantK := bloomSize(int(capacity), layerFPRate(fpRate, int(i)))
+		if words != uint64(wantWords) || k != uint32(wantK) {
+			return cr.n, errBadBloom
+		}
+		l := &bloomLayer{capacity: int(capacity), count: int(n), k: int(k)}
+		//按块读入，文件比记录的短时不会先分配整个位数组
+		for uint64(len(l.bits)) < words && cr.err == nil {
+			chunk := make([]uint64, min64(words-uint64(len(l.bits)), 1<<16))
+			cr.read(chunk)
+			l.bits = append(l.bits, chunk...)
+		}
+		layers = append(layers, l)
+		count += l.count
+	}
+	if cr.err != nil {
+		if cr.err == io.EOF {
+			cr.err = io.ErrUnexpectedEOF
+		}
+		return cr.n, cr.err
+	}
+	b.FPRate = fpRate
+	b.layers = layers
+	b.count = count
+	return cr.n, nil
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) write(vs ...interface{}) {
+	for _, v := range vs {
+		if cw.err != nil {
+			return
+		}
+		if p, ok := v.([]byte); ok {
+			_, cw.err = cw.w.Write(p)
+			cw.n += int64(len(p))
+			continue
+		}
+		cw.err = binary.Write(cw.w, binary.BigEndian, v)
+		cw.n += int64(binary.Size(v))
+	}
+}
+
+type countReader struct {
+	r   *bufio.Reader
+	n   int64
+	err error
+}
+
+func (cr *countReader) read(vs ...interface{}) {
+	for _, v := range vs {
+		if cr.err != nil {
+			return
+		}
+		if p, ok := v.([]byte); ok {
+			_, cr.err = io.ReadFull(cr.r, p)
+			cr.n += int64(len(p))
+			continue
+		}
+		cr.err = binary.Read(cr.r, binary.BigEndian, v)
+		cr.n += int64(binary.Size(v))
+	}
+}
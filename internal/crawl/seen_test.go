@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestBloomSeen(t *testing.T) {
+	const n, fp = 20000, 0.01
+	b := NewBloomSeen(1000, fp)
+	for i := 0; i < n; i++ {
+		b.Add("https://www.jianshu.com/p/" + strconv.Itoa(i))
+	}
+	if len(b.layers) < 2 {
+		t.Fatalf("layers = %d, want the filter to grow", len(b.layers))
+	}
+	for i := 0; i < n; i++ {
+		if !b.Has("https://www.jianshu.com/p/" + strconv.Itoa(i)) {
+			t.Fatalf("added key %d not found", i)
+		}
+	}
+	fps := 0
+	for i := n; i < 2*n; i++ {
+		if b.Has("https://www.jianshu.com/p/" + strconv.Itoa(i)) {
+			fps++
+		}
+	}
+	if rate := float64(fps) / n; rate > fp {
+		t.Errorf("false positive rate = %.4f, want <= %.4f", rate, fp)
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	c := NewBloomSeen(1, 0.5)
+	if _, err := c.ReadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != b.Len() || c.FPRate != fp || !c.Has("https://www.jianshu.com/p/7") {
+		t.Errorf("read back Len = %d FPRate = %g, want %d %g", c.Len(), c.FPRate, b.Len(), fp)
+	}
+	if _, err := c.ReadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("ReadFrom of a truncated file succeeded")
+	}
+}
+
+func TestBloomSeenCorrupt(t *testing.T) {
+	//header 返回只有一层的文件头，位数组的内容没有写出
+	header := func(fp float64, capacity, count uint64, k uint32, words uint64) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(bloomMagic)
+		for _, v := range []interface{}{uint32(bloomVersion), math.Float64bits(fp), uint32(1), capacity, count, k, words} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+		return buf.Bytes()
+	}
+	words, k := bloomSize(1024, layerFPRate(0.001, 0))
+	maxWords, maxK := bloomSize(math.MaxInt32, layerFPRate(0.001, 0))
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"huge word count", header(0.001, 1024, 0, uint32(k), 1<<32), errBadBloom},
+		{"word count off by one", header(0.001, 1024, 0, uint32(k), uint64(words+1)), errBadBloom},
+		{"wrong k", header(0.001, 1024, 0, uint32(k+1), uint64(words)), errBadBloom},
+		{"count over capacity", header(0.001, 1024, 1025, uint32(k), uint64(words)), errBadBloom},
+		{"bad fp rate", header(math.NaN(), 1024, 0, uint32(k), uint64(words)), errBadBloom},
+		{"huge capacity", header(0.001, 1<<40, 0, uint32(k), 1<<40), errBadBloom},
+		//记录的大小和容量一致但文件里没有位数组，应当在读完之前失败，而不是先分配几百 MB
+		{"missing bits", header(0.001, math.MaxInt32, 0, uint32(maxK), uint64(maxWords)), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		b := NewBloomSeen(1024, 0.001)
+		if _, err := b.ReadFrom(bytes.NewReader(tt.data)); err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		if b.Len() != 0 || len(b.layers) != 1 {
+			t.Errorf("%s: failed ReadFrom changed the filter", tt.name)
+		}
+	}
+}
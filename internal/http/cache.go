@@ -0,0 +1,473 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotCached is returned in offline mode, or for an only-if-cached
+// request, when the cache has no response for the request. Only GET
+// responses are cached, so offline every other request fails with it.
+var ErrNotCached = errors.New("http: response not in cache")
+
+// Cache is a private, disk-backed HTTP cache following RFC 7234.
+//
+// Only GET responses are stored. Entries are keyed by URL and by the
+// request headers named in the response's Vary header. A fresh entry is
+// served without contacting the server; a stale one is revalidated with
+// If-None-Match / If-Modified-Since and served again on 304 Not Modified.
+// Unsafe requests (POST, PUT, DELETE, PATCH) invalidate the entries for
+// their URL.
+type Cache struct {
+	Dir string
+
+	// Offline serves every request from the cache, fresh or not, and
+	// never touches the network. Requests that are not cached fail
+	// with ErrNotCached. Handy for working on parsers.
+	Offline bool
+
+	mu sync.Mutex
+}
+
+// NewCache returns a cache that stores its entries under dir.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// UseCache makes c read through cache. A nil cache disables caching.
+func (c *Client) UseCache(cache *Cache) *Client {
+	c.Cache = cache
+	return c
+}
+
+// cacheEntry is a stored response. On disk it is one line of JSON
+// followed by the body.
+type cacheEntry struct {
+	URL          string            `json:"url"`
+	Status       string            `json:"status"`
+	StatusCode   int               `json:"status_code"`
+	Header       [][2]string       `json:"header"`
+	Vary         map[string]string `json:"vary,omitempty"` // request header values the entry was selected by
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+
+	body []byte
+}
+
+// cacheableStatus lists the status codes that are cacheable by default
+// (RFC 7231, section 6.1) and that we bother to store.
+var cacheableStatus = map[int]bool{
+	StatusOK: true, StatusNonAuthoritativeInfo: true, StatusNoContent: true,
+	StatusMultipleChoices: true, StatusMovedPermanently: true,
+	StatusNotFound: true, StatusGone: true,
+}
+
+type sendFunc func(*Request) (*Response, *HttpClientError)
+
+// do serves req from the cache if it can, and otherwise sends it and
+// stores the response.
+func (cache *Cache) do(req *Request, send sendFunc) (*Response, *HttpClientError) {
+	if req.Method != "GET" {
+		if cache.Offline {
+			return nil, NewHttpClientError(ErrNotCached)
+		}
+		resp, hcerr := send(req)
+		if hcerr == nil && req.Method != "HEAD" && resp.StatusCode < 400 {
+			cache.invalidate(req.URL.String())
+		}
+		return resp, hcerr
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok && !cache.Offline {
+		return send(req)
+	}
+	e, err := cache.load(req)
+	if err != nil {
+		return nil, NewHttpClientError(err)
+	}
+
+	_, onlyIfCached := reqCC["only-if-cached"]
+	if cache.Offline || onlyIfCached {
+		if e == nil {
+			return nil, NewHttpClientError(ErrNotCached)
+		}
+		return e.response(req), nil
+	}
+
+	now := time.Now()
+	if e != nil && e.fresh(reqCC, now) {
+		return e.response(req), nil
+	}
+
+	// Revalidate a stale entry, unless the caller asked for a
+	// conditional request of their own.
+	conditional := false
+	if e != nil && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		h := e.header()
+		if etag := h.Get("Etag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+			conditional = true
+		}
+		if lm := h.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+			conditional = true
+		}
+	}
+
+	resp, hcerr := send(req)
+	if hcerr != nil {
+		return nil, hcerr
+	}
+	if conditional {
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		if resp.StatusCode == StatusNotModified {
+			drain(resp)
+			e.update(resp.Header, now, time.Now())
+			if err := cache.store(req, e); err != nil {
+				return nil, NewHttpClientError(err)
+			}
+			cached := e.response(req)
+			cached.Attempts = resp.Attempts
+			return cached, nil
+		}
+	}
+
+	if !storable(reqCC, resp) {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, NewHttpClientError(err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	e = &cacheEntry{
+		URL:          req.URL.String(),
+		Status:       resp.Status,
+		StatusCode:   resp.StatusCode,
+		RequestTime:  now,
+		ResponseTime: time.Now(),
+		body:         body,
+	}
+	e.setHeader(resp.Header)
+	if names := varyNames(resp.Header); len(names) > 0 {
+		e.Vary = make(map[string]string)
+		for _, name := range names {
+			e.Vary[name] = req.Header.Get(name)
+		}
+	}
+	if err := cache.store(req, e); err != nil {
+		return nil, NewHttpClientError(err)
+	}
+	return resp, nil
+}
+
+// storable reports whether resp may be stored (RFC 7234, section 3).
+func storable(reqCC map[string]string, resp *Response) bool {
+	if !cacheableStatus[resp.StatusCode] {
+		return false
+	}
+	if _, ok := reqCC["no-store"]; ok {
+		return false
+	}
+	if _, ok := parseCacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	for _, name := range varyNames(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// fresh reports whether e can be served without revalidation
+// (RFC 7234, section 4.2).
+func (e *cacheEntry) fresh(reqCC map[string]string, now time.Time) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	h := e.header()
+	respCC := parseCacheControl(h)
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+
+	age := e.age(h, now)
+	lifetime := e.lifetime(h, respCC)
+	if v, ok := reqCC["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil && time.Duration(secs)*time.Second < lifetime {
+			lifetime = time.Duration(secs) * time.Second
+		}
+	}
+	if v, ok := reqCC["min-fresh"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			age += time.Duration(secs) * time.Second
+		}
+	}
+	return age < lifetime
+}
+
+// lifetime is the freshness lifetime of e (RFC 7234, section 4.2.1).
+func (e *cacheEntry) lifetime(h *Header, respCC map[string]string) time.Duration {
+	if v, ok := respCC["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		return 0
+	}
+	date := e.date(h)
+	if v := h.Get("Expires"); v != "" {
+		exp, err := ParseTime(v)
+		if err != nil {
+			return 0 // an invalid Expires means already expired
+		}
+		return exp.Sub(date)
+	}
+	// Heuristic freshness: 10% of the time since the last modification.
+	if v := h.Get("Last-Modified"); v != "" {
+		if lm, err := ParseTime(v); err == nil && lm.Before(date) {
+			return date.Sub(lm) / 10
+		}
+	}
+	return 0
+}
+
+// age is the current age of e (RFC 7234, section 4.2.3).
+func (e *cacheEntry) age(h *Header, now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date(h))
+	if apparent < 0 {
+		apparent = 0
+	}
+	if v, err := strconv.Atoi(h.Get("Age")); err == nil {
+		if d := time.Duration(v)*time.Second + e.ResponseTime.Sub(e.RequestTime); d > apparent {
+			apparent = d
+		}
+	}
+	return apparent + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) date(h *Header) time.Time {
+	if d, err := ParseTime(h.Get("Date")); err == nil {
+		return d
+	}
+	return e.ResponseTime
+}
+
+func (e *cacheEntry) header() *Header {
+	h := NewHeader()
+	for _, kv := range e.Header {
+		h.Add(kv[0], kv[1])
+	}
+	return h
+}
+
+func (e *cacheEntry) setHeader(h *Header) {
+	e.Header = e.Header[:0]
+	for el := h.List.Front(); el != nil; el = el.Next() {
+		kv := el.Value.(*KeyValue)
+		// Cookies belong to the jar, not to the cache.
+		if kv.Key == "Set-Cookie" {
+			continue
+		}
+		e.Header = append(e.Header, [2]string{kv.Key, kv.Value})
+	}
+}
+
+// update merges the headers of a 304 response into e
+// (RFC 7234, section 4.3.4).
+func (e *cacheEntry) update(h *Header, requestTime, responseTime time.Time) {
+	stored := e.header()
+	for el := h.List.Front(); el != nil; el = el.Next() {
+		kv := el.Value.(*KeyValue)
+		stored.Del(kv.Key)
+	}
+	for el := h.List.Front(); el != nil; el = el.Next() {
+		kv := el.Value.(*KeyValue)
+		stored.Add(kv.Key, kv.Value)
+	}
+	e.setHeader(stored)
+	e.RequestTime, e.ResponseTime = requestTime, responseTime
+}
+
+// response builds a Response for req from e.
+func (e *cacheEntry) response(req *Request) *Response {
+	h := e.header()
+	h.Set("Age", strconv.Itoa(int(e.age(h, time.Now())/time.Second)))
+	return &Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+		FromCache:     true,
+	}
+}
+
+// parseCacheControl parses the Cache-Control directives in h into a
+// map of lowercase directive to (unquoted) argument.
+func parseCacheControl(h *Header) map[string]string {
+	cc := make(map[string]string)
+	values, _ := h.FindAll("Cache-Control")
+	if p := h.Get("Pragma"); len(values) == 0 && strings.Contains(strings.ToLower(p), "no-cache") {
+		cc["no-cache"] = ""
+	}
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg := part, ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name, arg = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+	return cc
+}
+
+// varyNames returns the canonical header names listed in Vary.
+func varyNames(h *Header) []string {
+	var names []string
+	values, _ := h.FindAll("Vary")
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// On disk, each URL has a .vary file listing the header names its
+// response varies by, and each variant an .entry file whose name hashes
+// the URL together with the values of those headers.
+
+func cacheKey(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (cache *Cache) path(key, ext string) string {
+	return filepath.Join(cache.Dir, key[:2], key+ext)
+}
+
+func (cache *Cache) variantKey(url string, names []string, req *Request) string {
+	parts := []string{"GET", url}
+	for _, name := range names {
+		parts = append(parts, name+": "+req.Header.Get(name))
+	}
+	return cacheKey(parts...)
+}
+
+// load returns the entry matching req, or nil.
+func (cache *Cache) load(req *Request) (*cacheEntry, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	url := req.URL.String()
+	b, err := ioutil.ReadFile(cache.path(cacheKey("GET", url), ".vary"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := strings.Fields(string(b))
+
+	f, err := os.Open(cache.path(cache.variantKey(url, names, req), ".entry"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	meta, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, nil // a truncated entry is a miss
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(meta, &e); err != nil {
+		return nil, nil
+	}
+	if e.body, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// store writes e as the variant of req's URL selected by req.
+func (cache *Cache) store(req *Request, e *cacheEntry) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	url := req.URL.String()
+	var names []string
+	for name := range e.Vary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(cache.path(cacheKey("GET", url), ".vary"), []byte(strings.Join(names, "\n"))); err != nil {
+		return err
+	}
+	return writeFileAtomic(cache.path(cache.variantKey(url, names, req), ".entry"),
+		append(append(meta, '\n'), e.body...))
+}
+
+// invalidate drops every variant of url.
+func (cache *Cache) invalidate(url string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	os.Remove(cache.path(cacheKey("GET", url), ".vary"))
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"io"
+	"io/ioutil"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// cacheTest serves handler and returns a client reading through a cache
+// in a fresh directory.
+func cacheTest(t *testing.T, handler stdhttp.HandlerFunc) (ts *httptest.Server, c *Client, hits *int32, cleanup func()) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits = new(int32)
+	ts = httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		atomic.AddInt32(hits, 1)
+		handler(w, r)
+	}))
+	c = NewClient().UseCache(NewCache(dir))
+	return ts, c, hits, func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// get sends a GET for url with the given header pairs and reads the body.
+func get(t *testing.T, c *Client, url string, header ...string) (*Response, string) {
+	req := NewRequest("GET", url)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.SetHeader(header[i], header[i+1])
+	}
+	resp, hcerr := req.SendBy(c)
+	if hcerr != nil {
+		t.Fatal(hcerr)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestCacheFresh(t *testing.T) {
+	ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "fresh")
+	})
+	defer cleanup()
+
+	if resp, body := get(t, c, ts.URL); resp.FromCache || body != "fresh" {
+		t.Errorf("first GET: FromCache %v, body %q", resp.FromCache, body)
+	}
+	resp, body := get(t, c, ts.URL)
+	if !resp.FromCache || body != "fresh" {
+		t.Errorf("second GET: FromCache %v, body %q", resp.FromCache, body)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("server saw %d requests, want 1", n)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	tests := []struct {
+		name, validator, value, condition string
+	}{
+		{"etag", "Etag", `"v1"`, "If-None-Match"},
+		{"last-modified", "Last-Modified", "Tue, 20 Mar 2018 10:12:00 GMT", "If-Modified-Since"},
+	}
+	for _, tt := range tests {
+		ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set(tt.validator, tt.value)
+			if r.Header.Get(tt.condition) == tt.value {
+				w.WriteHeader(stdhttp.StatusNotModified)
+				return
+			}
+			io.WriteString(w, "body")
+		})
+
+		get(t, c, ts.URL)
+		resp, body := get(t, c, ts.URL)
+		if !resp.FromCache || resp.StatusCode != StatusOK || body != "body" {
+			t.Errorf("%s: revalidated GET: FromCache %v, status %d, body %q", tt.name, resp.FromCache, resp.StatusCode, body)
+		}
+		if n := atomic.LoadInt32(hits); n != 2 {
+			t.Errorf("%s: server saw %d requests, want 2", tt.name, n)
+		}
+		cleanup()
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	})
+	defer cleanup()
+
+	for i, lang := range []string{"zh-CN", "en", "zh-CN", "en"} {
+		resp, body := get(t, c, ts.URL, "Accept-Language", lang)
+		if body != lang || resp.FromCache != (i >= 2) {
+			t.Errorf("GET %d (%s): FromCache %v, body %q", i+1, lang, resp.FromCache, body)
+		}
+	}
+	if n := atomic.LoadInt32(hits); n != 2 {
+		t.Errorf("server saw %d requests, want 2", n)
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+		io.WriteString(w, "secret")
+	})
+	defer cleanup()
+
+	get(t, c, ts.URL)
+	if resp, _ := get(t, c, ts.URL); resp.FromCache {
+		t.Error("no-store response served from cache")
+	}
+	if n := atomic.LoadInt32(hits); n != 2 {
+		t.Errorf("server saw %d requests, want 2", n)
+	}
+	filepath.Walk(c.Cache.Dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			t.Errorf("no-store response written to %s", path)
+		}
+		return nil
+	})
+}
+
+func TestCacheOfflineMiss(t *testing.T) {
+	ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		io.WriteString(w, "online")
+	})
+	defer cleanup()
+
+	get(t, c, ts.URL+"/cached")
+	c.Cache.Offline = true
+	if resp, body := get(t, c, ts.URL+"/cached"); !resp.FromCache || body != "online" {
+		t.Errorf("offline hit: FromCache %v, body %q", resp.FromCache, body)
+	}
+	_, hcerr := NewRequest("GET", ts.URL+"/missing").SendBy(c)
+	if hcerr == nil || hcerr.err != ErrNotCached {
+		t.Errorf("offline miss: err = %v, want ErrNotCached", hcerr)
+	}
+	//只缓存 GET，其他方法离线时一律不访问网络
+	_, hcerr = NewRequest("POST", ts.URL+"/cached").SetBody("q=go").SendBy(c)
+	if hcerr == nil || hcerr.err != ErrNotCached {
+		t.Errorf("offline POST: err = %v, want ErrNotCached", hcerr)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("server saw %d requests, want 1", n)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ts, c, hits, cleanup := cacheTest(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, r.Method)
+	})
+	defer cleanup()
+
+	get(t, c, ts.URL+"/note")
+	if resp, _ := get(t, c, ts.URL+"/note"); !resp.FromCache {
+		t.Fatal("second GET not served from cache")
+	}
+	resp, hcerr := NewRequest("POST", ts.URL+"/note").SetBody("title=x").SendBy(c)
+	if hcerr != nil {
+		t.Fatal(hcerr)
+	}
+	drain(resp)
+	if resp, body := get(t, c, ts.URL+"/note"); resp.FromCache || body != "GET" {
+		t.Errorf("GET after POST: FromCache %v, body %q, want a fresh response", resp.FromCache, body)
+	}
+	if n := atomic.LoadInt32(hits); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+}
@@ -81,7 +81,24 @@ type Client struct {
 	// instead of implementing CancelRequest.
 	Timeout   time.Duration
 	LastError error
+
+	// RetryPolicy controls retries of failed requests in Do.
+	// If nil, each request is attempted once.
+	RetryPolicy *RetryPolicy
+
+	// Cache, if set, serves GET requests from a disk cache and
+	// stores their responses. See Cache.
+	Cache *Cache
+
+	// CheckRequest, if set, is called before a request goes out to
+	// the network, after any cache lookup and before the first
+	// attempt. A non-nil error aborts the request and is returned
+	// from Do. It lets every user of a shared Client be held to the
+	// same policy, such as robots.txt.
+	CheckRequest func(req *Request) error
+
 	referer   string
+	refererMu sync.Mutex // guards referer; workers share one Client
 }
 
 // DefaultClient is the default Client and is used by Get, Head, and Post.
@@ -208,36 +225,89 @@ func (c *Client) send(req *Request, deadline time.Time) (*Response, error) {
 // non-nil error only occurs when CheckRedirect fails, and even then
 // the returned Response.Body is already closed.
 //
+// If c.RetryPolicy is set, replayable requests that fail with a
+// transient error or a retryable status are sent again after a backoff;
+// Response.Attempts reports how many times the request was sent.
+// If c.Cache is set, GET requests go through the cache first.
+//
 // Generally Get, Post, or PostForm will be used instead of Do.
 func (c *Client) Do(req *Request) (*Response, *HttpClientError) {
 	if err := req.presend(); err != nil {
 		return nil, NewHttpClientError(err)
 	}
-	
+
+	if c.Cache != nil {
+		return c.Cache.do(req, c.doRetry)
+	}
+	return c.doRetry(req)
+}
+
+// doRetry sends req, retrying it under c.RetryPolicy.
+func (c *Client) doRetry(req *Request) (*Response, *HttpClientError) {
+	if c.CheckRequest != nil {
+		if err := c.CheckRequest(req); err != nil {
+			return nil, NewHttpClientError(err)
+		}
+	}
+	// The jar adds its cookies to req on every send; remember the
+	// caller's Cookie header so a retry does not repeat them.
+	cookie, hasCookie := req.Header.Find("Cookie")
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(req)
+		p := c.RetryPolicy
+		if p == nil || !req.isReplayable() {
+			return finishAttempt(resp, err, attempt)
+		}
+		wait, retry := p.retryWait(resp, err, attempt)
+		if !retry {
+			return finishAttempt(resp, err, attempt)
+		}
+		if resp != nil {
+			drain(resp)
+		}
+		if hasCookie {
+			req.Header.Set("Cookie", cookie)
+		} else {
+			req.Header.Del("Cookie")
+		}
+		time.Sleep(wait)
+	}
+}
+
+func finishAttempt(resp *Response, err error, attempt int) (*Response, *HttpClientError) {
+	if err != nil {
+		return nil, NewHttpClientError(err)
+	}
+	resp.Attempts = attempt
+	return resp, nil
+}
+
+// do sends req once, following redirects.
+func (c *Client) do(req *Request) (*Response, error) {
 	method := valueOrDefault(req.Method, "GET")
 	if method == "GET" || method == "HEAD" {
 		resp, err := c.doFollowingRedirects(req, shouldRedirectGet)
 		if err != nil {
-			return nil, NewHttpClientError(err)
+			return nil, err
 		}
-		c.referer = req.URL.String()
+		c.setReferer(req.URL.String())
 		resp.Client = c
 		return resp, nil
 	}
 	if method == "POST" || method == "PUT" {
 		resp, err := c.doFollowingRedirects(req, shouldRedirectPost)
 		if err != nil {
-			return nil, NewHttpClientError(err)
+			return nil, err
 		}
-		c.referer = req.URL.String()
+		c.setReferer(req.URL.String())
 		resp.Client = c
 		return resp, nil
 	}
 	resp, err := c.send(req, c.deadline())
 	if err != nil {
-		return nil, NewHttpClientError(err)
+		return nil, err
 	}
-	c.referer = req.URL.String()
+	c.setReferer(req.URL.String())
 	resp.Client = c
 	return resp, nil
 }
@@ -257,9 +327,17 @@ func (c *Client) transport() RoundTripper {
 }
 
 func (c *Client) GetReferer() string {
+	c.refererMu.Lock()
+	defer c.refererMu.Unlock()
 	return c.referer
 }
 
+func (c *Client) setReferer(referer string) {
+	c.refererMu.Lock()
+	c.referer = referer
+	c.refererMu.Unlock()
+}
+
 // send issues an HTTP request.
 // Caller should close resp.Body when done reading from it.
 func send(ireq *Request, rt RoundTripper, deadline time.Time) (*Response, error) {
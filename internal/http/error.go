@@ -28,6 +28,10 @@ func NewHttpClientError(err interface{})*HttpClientError{
 func (e *HttpClientError)Error()string{
 	return e.err.Error()
 }
+// Unwrap returns the underlying error, for errors.Is and errors.As.
+func (e *HttpClientError)Unwrap()error{
+	return e.err
+}
 func (e *HttpClientError)IsDnsError()bool{
 	if _, ok :=e.err.(*net.DNSError);ok{
 		return true
@@ -105,6 +105,15 @@ type Response struct {
 
 	Client *Client
 
+	// Attempts is the number of times Client.Do sent the request,
+	// 1 unless it was retried under the client's RetryPolicy, and 0
+	// if the response came from the client's Cache without a request.
+	Attempts int
+
+	// FromCache reports whether the response was served from the
+	// client's Cache, possibly after a 304 revalidation.
+	FromCache bool
+
 	// TLS contains information about the TLS connection on which the
 	// response was received. It is nil for unencrypted responses.
 	// The pointer is shared between responses and should not be
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes when and how Client.Do retries a failed request.
+// Only replayable requests (GET, HEAD, OPTIONS, TRACE without a body)
+// are retried; the attempt count is reported in Response.Attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry. Each further retry
+	// doubles it, up to MaxBackoff, and a random jitter of up to the
+	// same amount is added ("equal jitter").
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// RetryStatus lists the status codes that are retried.
+	RetryStatus []int
+	// MaxRetryAfter caps how long a Retry-After header may make us
+	// wait. A longer Retry-After ends the retries and returns the
+	// response as is.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy retries timeouts, temporary network errors,
+// connection resets, 429 and 5xx gateway errors up to 4 attempts in
+// total. Permanent failures such as an unknown host are not retried.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:   4,
+		MinBackoff:    500 * time.Millisecond,
+		MaxBackoff:    30 * time.Second,
+		RetryStatus:   []int{StatusTooManyRequests, StatusBadGateway, StatusServiceUnavailable, StatusGatewayTimeout},
+		MaxRetryAfter: 2 * time.Minute,
+	}
+}
+
+// Retry sets the retry policy of c. A nil policy disables retries.
+func (c *Client) Retry(p *RetryPolicy) *Client {
+	c.RetryPolicy = p
+	return c
+}
+
+// backoff returns the wait before the given retry (1 for the first).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryWait reports whether the outcome of an attempt should be retried
+// and how long to wait before doing so.
+func (p *RetryPolicy) retryWait(resp *Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if err != nil {
+		return p.backoff(attempt), isTransient(err)
+	}
+	retryable := false
+	for _, code := range p.RetryStatus {
+		if resp.StatusCode == code {
+			retryable = true
+			break
+		}
+	}
+	if !retryable {
+		return 0, false
+	}
+	wait := p.backoff(attempt)
+	if ra, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if p.MaxRetryAfter > 0 && ra > p.MaxRetryAfter {
+			return 0, false
+		}
+		if ra > wait {
+			wait = ra
+		}
+	}
+	return wait, true
+}
+
+// retryAfter parses a Retry-After value, either delay-seconds or an
+// HTTP-date (RFC 7231, section 7.1.3).
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// isTransient reports whether err is worth retrying: timeouts,
+// temporary network errors, resets and connections closed before a
+// response arrived. A net.Error that is neither a timeout nor temporary,
+// like a DNS "no such host", fails the same way on every attempt.
+func isTransient(err error) bool {
+	if ue, ok := err.(*Error); ok {
+		err = ue.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if ne, ok := err.(net.Error); ok && (ne.Timeout() || ne.Temporary()) {
+		return true
+	}
+	// The transport reports resets and early closes as plain errors.
+	msg := err.Error()
+	for _, s := range []string{"connection reset", "broken pipe", "server closed", "EOF"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// drain discards what is left of a response body we are not going to
+// return, so that the connection can be reused for the retry.
+func drain(resp *Response) {
+	const maxDrain = 4 << 10
+	io.CopyN(ioutil.Discard, resp.Body, maxDrain)
+	resp.Body.Close()
+}
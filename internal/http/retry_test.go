@@ -0,0 +1,163 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2018, 3, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 120 * time.Second, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"", 0, false},
+		{"Tue, 20 Mar 2018 10:01:30 GMT", 90 * time.Second, true},
+		{"Tue, 20 Mar 2018 09:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.in, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{30, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tt.retry); d < tt.max/2 || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.retry, d, tt.max/2, tt.max)
+			}
+		}
+	}
+	if d := (&RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("backoff without MinBackoff = %v, want 0", d)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no such host", &net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}, false},
+		{"dns timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, true},
+		{"reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{"unexpected EOF", &Error{Op: "Get", URL: "http://example.com/", Err: io.ErrUnexpectedEOF}, true},
+		{"server closed", errors.New("http: server closed idle connection"), true},
+		{"other", errors.New("http: no Host in request URL"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: isTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	p := &RetryPolicy{
+		MaxAttempts:   3,
+		RetryStatus:   []int{StatusServiceUnavailable},
+		MaxRetryAfter: time.Minute,
+	}
+	status := func(code int, retryAfter string) *Response {
+		h := NewHeader()
+		if retryAfter != "" {
+			h.Set("Retry-After", retryAfter)
+		}
+		return &Response{StatusCode: code, Header: h}
+	}
+	tests := []struct {
+		name    string
+		resp    *Response
+		err     error
+		attempt int
+		retry   bool
+		wait    time.Duration
+	}{
+		{"retryable status", status(503, ""), nil, 1, true, 0},
+		{"other status", status(500, ""), nil, 1, false, 0},
+		{"retry after", status(503, "7"), nil, 1, true, 7 * time.Second},
+		{"retry after too long", status(503, "3600"), nil, 1, false, 0},
+		{"attempts used up", status(503, ""), nil, 3, false, 0},
+		{"transient error", nil, io.ErrUnexpectedEOF, 1, true, 0},
+		{"permanent error", nil, &net.DNSError{Err: "no such host", IsNotFound: true}, 1, false, 0},
+	}
+	for _, tt := range tests {
+		wait, retry := p.retryWait(tt.resp, tt.err, tt.attempt)
+		if retry != tt.retry || retry && wait != tt.wait {
+			t.Errorf("%s: retryWait = %v, %v; want %v, %v", tt.name, wait, retry, tt.wait, tt.retry)
+		}
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		if atomic.AddInt32(&hits, 1)%2 == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(stdhttp.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	c := NewClient().Retry(&RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  10 * time.Millisecond,
+		RetryStatus: []int{StatusServiceUnavailable},
+	})
+	start := time.Now()
+	resp, err := NewRequest("GET", ts.URL).SendBy(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drain(resp)
+	if resp.StatusCode != StatusOK || resp.Attempts != 2 {
+		t.Errorf("GET: status %d after %d attempts, want 200 after 2", resp.StatusCode, resp.Attempts)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("GET: retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+
+	// A request with a body cannot be replayed, so it is not retried.
+	resp, err = NewRequest("POST", ts.URL).SetBody("a=1").SendBy(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drain(resp)
+	if resp.StatusCode != StatusServiceUnavailable || resp.Attempts != 1 {
+		t.Errorf("POST: status %d after %d attempts, want 503 after 1", resp.StatusCode, resp.Attempts)
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("server saw %d requests, want 3", n)
+	}
+}
@@ -0,0 +1,129 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// SiteAdapter 封装一个站点的页面解析规则，抓取流程通过它与具体站点解耦。
+// 新增站点时实现该接口并在 init 中调用 RegisterAdapter 即可
+type SiteAdapter interface {
+	// Name 返回站点名，如 "jianshu"
+	Name() string
+	// Match 判断地址是否属于该站点
+	Match(u *http.URL) bool
+	// HomeURL 返回站点首页，抓取没有指定地址时从这里开始
+	HomeURL() string
+	// ParseList 从列表页（首页、专题、作者主页等）中解析文章
+	ParseList(body string) ([]*Article, error)
+	// ParseDetail 从文章页中解析文章详情
+	ParseDetail(body string) (*ArticleDetail, error)
+}
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   []SiteAdapter
+)
+
+// RegisterAdapter 注册一个站点，后注册的先匹配，以便覆盖内置的规则
+func RegisterAdapter(a SiteAdapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	adapters = append([]SiteAdapter{a}, adapters...)
+}
+
+// Adapters 返回已注册的全部站点
+func Adapters() []SiteAdapter {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	return append([]SiteAdapter(nil), adapters...)
+}
+
+// AdapterFor 按地址的域名选出对应的站点，rawurl 必须是绝对地址
+func AdapterFor(rawurl string) (SiteAdapter, error) {
+	u, err := http.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%s 不是绝对地址", rawurl)
+	}
+	for _, a := range Adapters() {
+		if a.Match(u) {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("没有适用于 %s 的站点解析规则", u.Host)
+}
+
+// FetchList 用地址对应站点的规则抓取并解析一个列表页，文章地址按列表页的地址补全
+func FetchList(httpClient *http.Client, url string) ([]*Article, error) {
+	a, err := AdapterFor(url)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fetchPage(httpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	arts, err := a.ParseList(body)
+	resolveArticles(url, arts)
+	return arts, err
+}
+
+// FetchDetail 用地址对应站点的规则抓取并解析一篇文章
+func FetchDetail(httpClient *http.Client, url string) (*ArticleDetail, error) {
+	a, err := AdapterFor(url)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fetchPage(httpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	d, err := a.ParseDetail(body)
+	if err != nil {
+		return nil, err
+	}
+	if d.Url == "" {
+		d.Url = url
+	}
+	d.Url = resolveURL(url, d.Url)
+	return d, nil
+}
+
+// resolveArticles 把列表中文章的地址按列表页的地址 page 补全
+func resolveArticles(page string, arts []*Article) {
+	for _, a := range arts {
+		a.Url = resolveURL(page, a.Url)
+	}
+}
+
+// hostMatches 判断 host 是否为 domain 本身或其子域名，忽略端口
+func hostMatches(host, domain string) bool {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// JianShu 是简书的解析规则
+type JianShu struct{}
+
+func (JianShu) Name() string { return "jianshu" }
+
+func (JianShu) Match(u *http.URL) bool { return hostMatches(u.Host, "jianshu.com") }
+
+func (JianShu) HomeURL() string { return JianShuHost + "/" }
+
+func (JianShu) ParseList(body string) ([]*Article, error) { return ParseArticles(body) }
+
+func (JianShu) ParseDetail(body string) (*ArticleDetail, error) { return ParseArticleDetail(body) }
+
+func init() {
+	RegisterAdapter(JianShu{})
+}
@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// testSite 是指向测试服务器的站点，按简书的页面结构解析
+type testSite struct{ home string }
+
+func (s testSite) Name() string { return "test" }
+
+func (s testSite) Match(u *http.URL) bool {
+	h, _ := http.Parse(s.home)
+	return u.Host == h.Host
+}
+
+func (s testSite) HomeURL() string { return s.home }
+
+func (s testSite) ParseList(body string) ([]*Article, error) { return ParseArticles(body) }
+
+func (s testSite) ParseDetail(body string) (*ArticleDetail, error) { return ParseArticleDetail(body) }
+
+// registerTestSite 注册 site，返回恢复原来的注册表的函数
+func registerTestSite(site SiteAdapter) (restore func()) {
+	saved := Adapters()
+	RegisterAdapter(site)
+	return func() {
+		adaptersMu.Lock()
+		adapters = saved
+		adaptersMu.Unlock()
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		want         bool
+	}{
+		{"jianshu.com", "jianshu.com", true},
+		{"www.jianshu.com", "jianshu.com", true},
+		{"WWW.JianShu.com:443", "jianshu.com", true},
+		{"notjianshu.com", "jianshu.com", false},
+		{"jianshu.com.evil.cn", "jianshu.com", false},
+		{"127.0.0.1:8080", "127.0.0.1", true},
+	}
+	for _, tt := range tests {
+		if got := hostMatches(tt.host, tt.domain); got != tt.want {
+			t.Errorf("hostMatches(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestAdapterFor(t *testing.T) {
+	if a, err := AdapterFor("https://www.jianshu.com/p/6603d0ad230f"); err != nil || a.Name() != "jianshu" {
+		t.Errorf("AdapterFor(jianshu) = %v, %v", a, err)
+	}
+	if _, err := AdapterFor("https://blog.example.com/"); err == nil {
+		t.Error("AdapterFor(unknown host) succeeded")
+	}
+	if _, err := AdapterFor("/p/6603d0ad230f"); err == nil {
+		t.Error("AdapterFor(relative url) succeeded")
+	}
+
+	//后注册的规则优先于内置的
+	rs, err := ParseRules([]byte(`{"name":"jianshu-rules","hosts":["jianshu.com"],"home":"https://www.jianshu.com/"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := registerTestSite(rs)
+	if a, _ := AdapterFor("https://www.jianshu.com/"); a != SiteAdapter(rs) {
+		t.Errorf("AdapterFor after RegisterAdapter = %v, want the registered rules", a)
+	}
+	restore()
+	if a, _ := AdapterFor("https://www.jianshu.com/"); a == nil || a.Name() != "jianshu" {
+		t.Errorf("AdapterFor after restore = %v, want the builtin adapter", a)
+	}
+}
+
+func TestFetchListResolvesAgainstPage(t *testing.T) {
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		fmt.Fprint(w, homeFixture)
+	}))
+	defer site.Close()
+	defer registerTestSite(testSite{site.URL + "/"})()
+
+	arts, err := FetchList(http.NewClient(), site.URL+"/blog/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arts) != 2 {
+		t.Fatalf("got %d articles, want 2", len(arts))
+	}
+	//相对地址按列表页本身补全，而不是补成简书的地址
+	if want := site.URL + "/p/6603d0ad230f"; arts[0].Url != want {
+		t.Errorf("Url = %q, want %q", arts[0].Url, want)
+	}
+}
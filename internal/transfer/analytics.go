@@ -0,0 +1,204 @@
+package transfer
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextStats 是对文章文本的统计，由 Analyzer 计算后挂在 Article.Stats 上
+type TextStats struct {
+	Chars       int           `json:"chars"`        //字数：汉字、假名、谚文各算一个，英文单词和数字串各算一个
+	ReadingTime time.Duration `json:"reading_time"` //估计的阅读时间
+	Keywords    []Keyword     `json:"keywords"`     //按 TF-IDF 从高到低
+	Script      ScriptMix     `json:"script"`
+}
+
+// Keyword 是一个关键词及其 TF-IDF 权重
+type Keyword struct {
+	Word   string  `json:"word"`
+	Weight float64 `json:"weight"`
+}
+
+// ScriptMix 是各类文字占全部文字（不含空白、标点）的比例
+type ScriptMix struct {
+	Han      float64 `json:"han"`
+	Latin    float64 `json:"latin"`
+	Digit    float64 `json:"digit"`
+	Kana     float64 `json:"kana"`
+	Hangul   float64 `json:"hangul"`
+	Other    float64 `json:"other"`
+	Language string  `json:"language"` //主要语言：zh、en、ja、ko，无法判断时为空
+}
+
+// Analyzer 计算文章的字数、阅读时间、关键词和文字构成。
+// IDF 来自此前分析过的全部文章，分析的文章越多关键词越准
+type Analyzer struct {
+	Seg *Segmenter
+	// TopK 保留的关键词个数，默认 10
+	TopK int
+	// CharsPerMinute 中文每分钟阅读的字数，默认 400
+	CharsPerMinute int
+	// WordsPerMinute 英文每分钟阅读的单词数，默认 200
+	WordsPerMinute int
+	// StopWords 不作为关键词的词
+	StopWords map[string]bool
+
+	mu   sync.Mutex
+	df   map[string]int
+	docs int
+}
+
+func NewAnalyzer() *Analyzer {
+	a := &Analyzer{
+		Seg:            NewSegmenter(),
+		TopK:           10,
+		CharsPerMinute: 400,
+		WordsPerMinute: 200,
+		StopWords:      make(map[string]bool),
+		df:             make(map[string]int),
+	}
+	for _, w := range []string{
+		"我们", "你们", "他们", "她们", "它们", "自己", "大家", "别人", "什么", "怎么", "怎样", "为什么",
+		"这个", "那个", "这些", "那些", "这样", "那样", "这里", "那里", "哪里", "一个", "一些", "一样",
+		"一直", "一定", "一起", "一种", "一下", "已经", "曾经", "正在", "现在", "以前", "以后", "后来",
+		"然后", "之后", "之前", "时候", "因为", "所以", "但是", "可是", "不过", "如果", "虽然", "而且",
+		"或者", "还是", "就是", "只是", "于是", "然而", "并且", "不是", "没有", "可以", "应该", "需要",
+		"知道", "觉得", "认为", "the", "and", "of", "to", "a", "in", "is", "it", "for", "on", "with",
+	} {
+		a.StopWords[w] = true
+	}
+	return a
+}
+
+// EnrichArticles 分析列表页文章的标题和摘要，结果写入 Stats
+func (an *Analyzer) EnrichArticles(arts []*Article) {
+	for _, a := range arts {
+		a.Stats = an.Analyze(a.Title + "\n" + a.Abstract)
+	}
+}
+
+// EnrichDetail 分析文章详情的标题和正文，结果写入 Stats
+func (an *Analyzer) EnrichDetail(d *ArticleDetail) {
+	d.Stats = an.Analyze(d.Title + "\n" + d.BodyText)
+}
+
+// Analyze 分析一段文本，并把它计入 IDF 的统计
+func (an *Analyzer) Analyze(s string) *TextStats {
+	st := &TextStats{}
+	var words int
+	st.Chars, words, st.Script = scriptMix(s)
+	han := st.Chars - words
+	if an.CharsPerMinute > 0 && an.WordsPerMinute > 0 {
+		minutes := float64(han)/float64(an.CharsPerMinute) + float64(words)/float64(an.WordsPerMinute)
+		st.ReadingTime = time.Duration(minutes * float64(time.Minute)).Round(time.Second)
+	}
+	st.Keywords = an.keywords(an.Seg.Cut(s))
+	return st
+}
+
+func (an *Analyzer) keywords(tokens []string) []Keyword {
+	tf := make(map[string]int)
+	n := 0
+	for _, t := range tokens {
+		if utf8.RuneCountInString(t) < 2 || an.StopWords[t] || isNumber(t) {
+			continue
+		}
+		tf[t]++
+		n++
+	}
+
+	an.mu.Lock()
+	an.docs++
+	for t := range tf {
+		an.df[t]++
+	}
+	kws := make([]Keyword, 0, len(tf))
+	for t, c := range tf {
+		//平滑的 IDF，只分析过一篇文章时退化为词频
+		idf := math.Log(float64(an.docs+1)/float64(an.df[t]+1)) + 1
+		kws = append(kws, Keyword{Word: t, Weight: float64(c) / float64(n) * idf})
+	}
+	an.mu.Unlock()
+
+	sort.Slice(kws, func(i, j int) bool {
+		if kws[i].Weight != kws[j].Weight {
+			return kws[i].Weight > kws[j].Weight
+		}
+		return kws[i].Word < kws[j].Word
+	})
+	if an.TopK > 0 && len(kws) > an.TopK {
+		kws = kws[:an.TopK]
+	}
+	return kws
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// scriptMix 统计字数、其中英文单词和数字串的个数，以及各类文字的比例
+func scriptMix(s string) (chars, words int, mix ScriptMix) {
+	var han, latin, digit, kana, hangul, other int
+	inWord := false
+	for _, r := range s {
+		word := false
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.IsDigit(r):
+			digit++
+			word = true
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			word = true
+		case unicode.IsLetter(r):
+			other++
+			word = true
+		default:
+			inWord = false
+			continue
+		}
+		if word && !inWord {
+			words++
+		}
+		inWord = word
+	}
+
+	chars = han + kana + hangul + words
+	total := float64(han + latin + digit + kana + hangul + other)
+	if total == 0 {
+		return chars, words, mix
+	}
+	mix = ScriptMix{
+		Han:    float64(han) / total,
+		Latin:  float64(latin) / total,
+		Digit:  float64(digit) / total,
+		Kana:   float64(kana) / total,
+		Hangul: float64(hangul) / total,
+		Other:  float64(other) / total,
+	}
+	switch {
+	case mix.Kana > 0.1:
+		mix.Language = "ja"
+	case mix.Hangul > 0.3:
+		mix.Language = "ko"
+	case mix.Han >= 0.3:
+		mix.Language = "zh"
+	case mix.Latin >= 0.5:
+		mix.Language = "en"
+	}
+	return chars, words, mix
+}
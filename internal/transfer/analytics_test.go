@@ -0,0 +1,29 @@
+package transfer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegmenterCut(t *testing.T) {
+	got := NewSegmenter().Cut("程序员学习Go语言，2018年开始写作。")
+	want := []string{"程序员", "学习", "go", "语言", "2018", "年", "开始", "写作"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cut = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	an := NewAnalyzer()
+	an.Analyze("今天天气不错，我们去公园散步。")
+	st := an.Analyze("编程需要坚持。我们每天写代码，编程能力才会提高。hello world")
+	if st.Chars != 23 {
+		t.Errorf("Chars = %d, want 23", st.Chars)
+	}
+	if len(st.Keywords) == 0 || st.Keywords[0].Word != "编程" {
+		t.Errorf("Keywords = %+v, want 编程 first", st.Keywords)
+	}
+	if st.Script.Language != "zh" {
+		t.Errorf("Language = %q, want zh", st.Script.Language)
+	}
+}
@@ -0,0 +1,214 @@
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 离线归档时需要把文章中的图片一起保存下来。简书的图片放在 upyun 上并开启了防盗链，
+// 请求时必须带上文章页作为 Referer，否则会返回 403
+
+// AssetStore 把图片按内容的 sha1 保存在 Dir 下，同样的图片只保存一份
+type AssetStore struct {
+	Dir string
+
+	httpClient *http.Client
+}
+
+func NewAssetStore(httpClient *http.Client, dir string) *AssetStore {
+	return &AssetStore{Dir: dir, httpClient: httpClient}
+}
+
+// Asset 是一张已经保存到本地的图片
+type Asset struct {
+	Src  string //原始地址
+	File string //本地文件路径
+	Hash string //内容的 sha1
+}
+
+// ArticleImages 返回正文html中全部图片的地址（已按文章地址 base 补全为绝对地址），
+// 懒加载的图片取 data-original-src，重复的地址只返回一次
+func ArticleImages(bodyHtml, base string) ([]string, error) {
+	nodes, err := parseFragment(bodyHtml)
+	if err != nil {
+		return nil, err
+	}
+	var srcs []string
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		imgs := findAll(n, byTag("img"))
+		if isElement(n, "img") {
+			imgs = append([]*html.Node{n}, imgs...)
+		}
+		for _, img := range imgs {
+			src := strings.TrimSpace(imageSrc(img))
+			if src == "" || strings.HasPrefix(src, "data:") {
+				continue
+			}
+			if src = resolveURL(base, src); seen[src] {
+				continue
+			}
+			seen[src] = true
+			srcs = append(srcs, src)
+		}
+	}
+	return srcs, nil
+}
+
+// Download 下载一张图片并保存，referer 应为图片所在的文章页
+func (s *AssetStore) Download(src, referer string) (*Asset, error) {
+	req := http.NewRequest(http.MethodGet, src).
+		SetHeader(`Accept`, `image/webp,image/apng,image/*,*/*;q=0.8`).
+		SetHeader(`Accept-Encoding`, `gzip, deflate`).
+		SetHeader(`Referer`, absURL(referer)).
+		SetHeader(`User-Agent`, USER_AGENT)
+	resp, hcerr := req.SendBy(s.httpClient)
+	if hcerr != nil {
+		return nil, hcerr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("下载图片 %s: %s", src, resp.Status)
+	}
+	b, err := resp.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum(b)
+	hash := hex.EncodeToString(sum[:])
+	file := filepath.Join(s.Dir, hash[:2], hash+imageExt(src, resp.Header.Get("Content-Type")))
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return &Asset{Src: src, File: file, Hash: hash}, nil
+}
+
+// imageExt 按地址或 Content-Type 推断扩展名，upyun 的地址常带有 ?imageMogr2/... 处理参数
+func imageExt(src, contentType string) string {
+	p := src
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	switch ext := strings.ToLower(path.Ext(p)); ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".bmp":
+		return ext
+	}
+	ct := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	switch ct {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	if exts, _ := mime.ExtensionsByType(ct); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".img"
+}
+
+// Localize 下载文章正文中的全部图片，并把 d.BodyHtml 中的图片地址改写为相对 baseDir 的本地路径。
+// 本地路径记在 d.LocalImages 中，之后调用 ToMarkdown 得到的 Markdown 同样指向本地图片。
+// 单张图片下载失败不影响其它图片，失败的图片保留原地址，错误汇总后返回
+func (s *AssetStore) Localize(d *ArticleDetail, baseDir string) ([]*Asset, error) {
+	srcs, err := ArticleImages(d.BodyHtml, d.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []*Asset
+	var failed []string
+	local := make(map[string]string)
+	for _, src := range srcs {
+		a, err := s.Download(src, d.Url)
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		rel, err := filepath.Rel(baseDir, a.File)
+		if err != nil {
+			rel = a.File
+		}
+		local[src] = filepath.ToSlash(rel)
+		assets = append(assets, a)
+	}
+
+	if d.BodyHtml, err = rewriteImages(d.BodyHtml, d.Url, local); err != nil {
+		return assets, err
+	}
+	saved := make(map[string]bool)
+	for _, src := range srcs {
+		if file, ok := local[src]; ok && !saved[file] {
+			saved[file] = true
+			d.LocalImages = append(d.LocalImages, file)
+		}
+	}
+	if len(failed) > 0 {
+		return assets, fmt.Errorf("%d 张图片下载失败: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return assets, nil
+}
+
+// rewriteImages 把正文中的图片地址（按 base 补全后）替换为 local 中对应的本地路径，并去掉懒加载属性
+func rewriteImages(bodyHtml, base string, local map[string]string) (string, error) {
+	if len(local) == 0 {
+		return bodyHtml, nil
+	}
+	nodes, err := parseFragment(bodyHtml)
+	if err != nil {
+		return bodyHtml, err
+	}
+	var sb strings.Builder
+	for _, n := range nodes {
+		imgs := findAll(n, byTag("img"))
+		if isElement(n, "img") {
+			imgs = append(imgs, n)
+		}
+		for _, img := range imgs {
+			file, ok := local[resolveURL(base, strings.TrimSpace(imageSrc(img)))]
+			if !ok {
+				continue
+			}
+			attrs := img.Attr[:0]
+			for _, a := range img.Attr {
+				switch a.Key {
+				case "src", "data-original-src", "data-src", "data-echo":
+					continue
+				}
+				attrs = append(attrs, a)
+			}
+			img.Attr = append(attrs, html.Attribute{Key: "src", Val: file})
+		}
+		if err := html.Render(&sb, n); err != nil {
+			return bodyHtml, err
+		}
+	}
+	return sb.String(), nil
+}
+
+// parseFragment 把一段正文html解析为节点列表
+func parseFragment(s string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type: html.ElementNode, Data: "div", DataAtom: atom.Div,
+	})
+}
@@ -0,0 +1,118 @@
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+func TestArticleImages(t *testing.T) {
+	body := `<p><img data-original-src="//upload-images.jianshu.io/a.jpg" src="data:image/gif;base64,R0lGOD"></p>
+<img src="/images/b.png"><div><img data-original-src="//upload-images.jianshu.io/a.jpg"><img src="data:image/png;base64,x"></div>`
+	tests := []struct {
+		base string
+		want []string
+	}{
+		{JianShuHost + "/p/6603d0ad230f", []string{"https://upload-images.jianshu.io/a.jpg", JianShuHost + "/images/b.png"}},
+		//其他站点的文章，相对地址和省略协议的地址都按文章地址补全
+		{"http://blog.example.com/2018/note.html", []string{"http://upload-images.jianshu.io/a.jpg", "http://blog.example.com/images/b.png"}},
+	}
+	for _, tt := range tests {
+		got, err := ArticleImages(body, tt.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("base %s: got %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestImageExt(t *testing.T) {
+	tests := []struct{ src, ct, want string }{
+		{"https://upload-images.jianshu.io/a.JPG?imageMogr2/auto-orient/strip", "", ".jpg"},
+		{"https://upload-images.jianshu.io/upload_images/123", "image/png", ".png"},
+		{"https://upload-images.jianshu.io/upload_images/123", "image/webp; q=1", ".webp"},
+		{"https://upload-images.jianshu.io/upload_images/123", "", ".img"},
+	}
+	for _, tt := range tests {
+		if got := imageExt(tt.src, tt.ct); got != tt.want {
+			t.Errorf("imageExt(%q, %q) = %q, want %q", tt.src, tt.ct, got, tt.want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	png := []byte("\x89PNG fake image")
+	site := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		//防盗链：没有 Referer 时返回 403
+		if r.Header.Get("Referer") == "" {
+			w.WriteHeader(nethttp.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/missing.jpg" {
+			nethttp.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer site.Close()
+
+	dir, err := ioutil.TempDir("", "asset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &ArticleDetail{}
+	d.Url = site.URL + "/p/6603d0ad230f"
+	d.BodyHtml = `<p>图一<img data-original-src="` + site.URL + `/a?imageMogr2/auto-orient" alt="一"></p>` +
+		`<p><img src="/b" alt="二"><img src="` + site.URL + `/missing.jpg"></p>`
+	store := NewAssetStore(http.NewClient(), filepath.Join(dir, "images"))
+	assets, err := store.Localize(d, dir)
+	if err == nil || !strings.Contains(err.Error(), "1 张图片下载失败") {
+		t.Errorf("err = %v, want one failed image", err)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("got %d assets, want 2", len(assets))
+	}
+
+	//两张图内容相同，按 sha1 只保存一份
+	sum := sha1.Sum(png)
+	hash := hex.EncodeToString(sum[:])
+	rel := "images/" + hash[:2] + "/" + hash + ".png"
+	if assets[0].Hash != hash || assets[0].File != assets[1].File || assets[0].File != filepath.Join(dir, filepath.FromSlash(rel)) {
+		t.Errorf("assets = %+v %+v, want %s", *assets[0], *assets[1], rel)
+	}
+	if b, err := ioutil.ReadFile(assets[0].File); err != nil || string(b) != string(png) {
+		t.Errorf("stored file = %q, %v", b, err)
+	}
+
+	//归档的正文中只有本地路径，不留下标记用的属性
+	if strings.Contains(d.BodyHtml, "data-original-src") || strings.Contains(d.BodyHtml, "data-local") ||
+		strings.Count(d.BodyHtml, `src="`+rel+`"`) != 2 {
+		t.Errorf("body not rewritten: %s", d.BodyHtml)
+	}
+	if !reflect.DeepEqual(d.LocalImages, []string{rel}) {
+		t.Errorf("LocalImages = %q, want %q", d.LocalImages, rel)
+	}
+	md, err := ToMarkdown(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"![一](" + rel + ")", "![二](" + rel + ")", "![](" + site.URL + "/missing.jpg)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown does not contain %q:\n%s", want, md)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 文章分类用多项式朴素贝叶斯：特征是分词后的词频，加一平滑。
+// 训练数据是人工标注过的 JSONL，每行一篇文章，模型保存为 json
+
+// LabeledArticle 是训练数据中的一行，Body 可以为空，此时只用标题和摘要
+type LabeledArticle struct {
+	Label    string `json:"label"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+	Body     string `json:"body"`
+	Url      string `json:"url"`
+}
+
+func (la *LabeledArticle) text() string {
+	return la.Title + "\n" + la.Abstract + "\n" + la.Body
+}
+
+// LoadLabeled 读取 JSONL 格式的标注数据，空行忽略，没有 label 的行视为错误
+func LoadLabeled(file string) ([]*LabeledArticle, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []*LabeledArticle
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024) //正文可能很长
+	line := 0
+	for sc.Scan() {
+		line++
+		b := sc.Bytes()
+		if len(strings.TrimSpace(string(b))) == 0 {
+			continue
+		}
+		var la LabeledArticle
+		if err := json.Unmarshal(b, &la); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, line, err)
+		}
+		if la.Label == "" {
+			return nil, fmt.Errorf("%s:%d: 缺少 label", file, line)
+		}
+		samples = append(samples, &la)
+	}
+	return samples, sc.Err()
+}
+
+// Classifier 是训练好的分类模型
+type Classifier struct {
+	Classes   []string                  `json:"classes"`
+	Docs      map[string]int            `json:"docs"`   //每个类别的文章数
+	Tokens    map[string]int            `json:"tokens"` //每个类别的总词数
+	Counts    map[string]map[string]int `json:"counts"` //每个类别中各个词出现的次数
+	VocabSize int                       `json:"vocab_size"`
+	Trained   time.Time                 `json:"trained"`
+	//训练时在内置词典之外加入的词，加载模型时用它重建同样的分词器，否则切出的词和训练时对不上
+	Dict map[string]int `json:"dict,omitempty"`
+
+	seg *Segmenter
+}
+
+// ErrNoLabeledData 表示训练数据中没有带类别的文章
+var ErrNoLabeledData = errors.New("没有带类别的训练数据")
+
+// TrainClassifier 用标注数据训练模型，seg 为 nil 时使用内置词典。没有 Label 的文章跳过，
+// 一篇带类别的文章都没有时返回 ErrNoLabeledData
+func TrainClassifier(samples []*LabeledArticle, seg *Segmenter) (*Classifier, error) {
+	if seg == nil {
+		seg = NewSegmenter()
+	}
+	c := &Classifier{
+		Docs:    make(map[string]int),
+		Tokens:  make(map[string]int),
+		Counts:  make(map[string]map[string]int),
+		Trained: time.Now(),
+		Dict:    seg.ExtraWords(),
+		seg:     seg,
+	}
+	vocab := make(map[string]bool)
+	for _, s := range samples {
+		if s.Label == "" {
+			continue
+		}
+		counts := c.Counts[s.Label]
+		if counts == nil {
+			counts = make(map[string]int)
+			c.Counts[s.Label] = counts
+			c.Classes = append(c.Classes, s.Label)
+		}
+		c.Docs[s.Label]++
+		for _, t := range c.features(s.text()) {
+			counts[t]++
+			c.Tokens[s.Label]++
+			vocab[t] = true
+		}
+	}
+	if len(c.Classes) == 0 {
+		return nil, ErrNoLabeledData
+	}
+	sort.Strings(c.Classes)
+	c.VocabSize = len(vocab)
+	return c, nil
+}
+
+// LoadClassifier 读取 Save 保存的模型，分词器按训练时的词典重建
+func LoadClassifier(file string) (*Classifier, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var c Classifier
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if len(c.Classes) == 0 {
+		return nil, fmt.Errorf("%s: 模型中没有类别", file)
+	}
+	c.seg = NewSegmenter()
+	for w, f := range c.Dict {
+		c.seg.AddWord(w, f)
+	}
+	return &c, nil
+}
+
+// Save 把模型保存为 json
+func (c *Classifier) Save(file string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// Predict 返回最可能的类别及其后验概率，模型中没有类别时返回 "", 0
+func (c *Classifier) Predict(text string) (label string, confidence float64) {
+	if len(c.Classes) == 0 {
+		return "", 0
+	}
+	tokens := c.features(text)
+	total := 0
+	for _, n := range c.Docs {
+		total += n
+	}
+
+	scores := make([]float64, len(c.Classes))
+	best := 0
+	for i, cls := range c.Classes {
+		score := math.Log(float64(c.Docs[cls]) / float64(total))
+		denom := math.Log(float64(c.Tokens[cls] + c.VocabSize + 1))
+		counts := c.Counts[cls]
+		for _, t := range tokens {
+			score += math.Log(float64(counts[t]+1)) - denom
+		}
+		scores[i] = score
+		if score > scores[best] {
+			best = i
+		}
+	}
+
+	//softmax，先减去最大值避免下溢
+	sum := 0.0
+	for _, s := range scores {
+		sum += math.Exp(s - scores[best])
+	}
+	return c.Classes[best], 1 / sum
+}
+
+// ClassifyArticles 给列表页文章按标题和摘要分类，结果写入 Category 和 CategoryScore
+func (c *Classifier) ClassifyArticles(arts []*Article) {
+	for _, a := range arts {
+		a.Category, a.CategoryScore = c.Predict(a.Title + "\n" + a.Abstract)
+	}
+}
+
+// ClassifyDetail 按标题、摘要和正文给文章详情分类
+func (c *Classifier) ClassifyDetail(d *ArticleDetail) {
+	d.Category, d.CategoryScore = c.Predict(d.Title + "\n" + d.Abstract + "\n" + d.BodyText)
+}
+
+func (c *Classifier) features(text string) []string {
+	if c.seg == nil {
+		c.seg = NewSegmenter()
+	}
+	var tokens []string
+	for _, t := range c.seg.Cut(text) {
+		//单字大多是虚词，对分类帮助不大
+		if utf8.RuneCountInString(t) < 2 || isNumber(t) {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// ClassMetrics 是一个类别的评估结果
+type ClassMetrics struct {
+	Label     string
+	Support   int //测试集中该类别的文章数
+	Precision float64
+	Recall    float64
+	F1        float64
+}
+
+// Evaluation 是模型在测试集上的评估结果
+type Evaluation struct {
+	Total    int
+	Correct  int
+	Accuracy float64
+	Classes  []*ClassMetrics
+}
+
+// Evaluate 在标注数据上评估模型
+func (c *Classifier) Evaluate(samples []*LabeledArticle) *Evaluation {
+	tp := make(map[string]int)
+	predicted := make(map[string]int)
+	actual := make(map[string]int)
+	ev := &Evaluation{Total: len(samples)}
+	for _, s := range samples {
+		label, _ := c.Predict(s.text())
+		predicted[label]++
+		actual[s.Label]++
+		if label == s.Label {
+			tp[label]++
+			ev.Correct++
+		}
+	}
+	if ev.Total > 0 {
+		ev.Accuracy = float64(ev.Correct) / float64(ev.Total)
+	}
+
+	labels := make(map[string]bool)
+	for l := range predicted {
+		labels[l] = true
+	}
+	for l := range actual {
+		labels[l] = true
+	}
+	for l := range labels {
+		m := &ClassMetrics{Label: l, Support: actual[l]}
+		if predicted[l] > 0 {
+			m.Precision = float64(tp[l]) / float64(predicted[l])
+		}
+		if actual[l] > 0 {
+			m.Recall = float64(tp[l]) / float64(actual[l])
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		ev.Classes = append(ev.Classes, m)
+	}
+	sort.Slice(ev.Classes, func(i, j int) bool {
+		return ev.Classes[i].Label < ev.Classes[j].Label
+	})
+	return ev
+}
+
+// SplitLabeled 按 holdout 的比例把每个类别的最后一部分文章划为测试集，各类别的比例保持不变
+func SplitLabeled(samples []*LabeledArticle, holdout float64) (train, test []*LabeledArticle) {
+	byLabel := make(map[string][]*LabeledArticle)
+	var labels []string
+	for _, s := range samples {
+		if _, ok := byLabel[s.Label]; !ok {
+			labels = append(labels, s.Label)
+		}
+		byLabel[s.Label] = append(byLabel[s.Label], s)
+	}
+	for _, l := range labels {
+		group := byLabel[l]
+		n := int(math.Round(float64(len(group)) * holdout))
+		if n >= len(group) {
+			n = len(group) - 1
+		}
+		train = append(train, group[:len(group)-n]...)
+		test = append(test, group[len(group)-n:]...)
+	}
+	return train, test
+}
@@ -0,0 +1,97 @@
+package transfer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifier(t *testing.T) {
+	samples := []*LabeledArticle{
+		{Label: "tech", Title: "Go 语言并发编程", Body: "程序员写代码，算法和数据结构"},
+		{Label: "tech", Title: "算法入门", Body: "学习算法需要写代码，编程能力很重要"},
+		{Label: "life", Title: "一个人的旅行", Body: "旅行中的城市和家乡，青春和梦想"},
+		{Label: "life", Title: "回到家乡", Body: "家乡的城市变了，朋友都去旅行了"},
+	}
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "model.json")
+	trained, err := TrainClassifier(samples, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trained.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadClassifier(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Article{Title: "程序员的算法笔记", Abstract: "每天写代码"}
+	c.ClassifyArticles([]*Article{a})
+	if a.Category != "tech" || a.CategoryScore <= 0.5 {
+		t.Errorf("Category = %q (%.2f), want tech", a.Category, a.CategoryScore)
+	}
+	if ev := c.Evaluate(samples); ev.Accuracy != 1 || len(ev.Classes) != 2 {
+		t.Errorf("Evaluate = %+v", ev)
+	}
+}
+
+func TestClassifierEmpty(t *testing.T) {
+	if _, err := TrainClassifier([]*LabeledArticle{{Title: "没有类别"}}, nil); err != ErrNoLabeledData {
+		t.Errorf("TrainClassifier without labels: err = %v, want ErrNoLabeledData", err)
+	}
+	var c Classifier
+	if label, conf := c.Predict("随便一段文字"); label != "" || conf != 0 {
+		t.Errorf("Predict on an empty model = %q, %v", label, conf)
+	}
+}
+
+func TestClassifierDictRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dict := filepath.Join(dir, "dict.txt")
+	if err := ioutil.WriteFile(dict, []byte("卷积 1000\n神经网络 1000 n\n梯度下降 1000\n手冲咖啡 1000\n器具 1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	seg := NewSegmenter()
+	if err := seg.LoadDict(dict); err != nil {
+		t.Fatal(err)
+	}
+	//这些词内置词典里没有，不用训练时的词典只会切成单字，全部被 features 丢掉
+	samples := []*LabeledArticle{
+		{Label: "ai", Title: "卷积神经网络", Body: "神经网络的梯度下降"},
+		{Label: "ai", Title: "梯度下降", Body: "卷积神经网络训练"},
+		{Label: "coffee", Title: "手冲咖啡", Body: "手冲咖啡器具"},
+	}
+	trained, err := TrainClassifier(samples, seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "model.json")
+	if err := trained.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadClassifier(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"手冲咖啡器具推荐", "神经网络和梯度下降"} {
+		want, wantConf := trained.Predict(text)
+		got, conf := c.Predict(text)
+		if got != want || conf != wantConf {
+			t.Errorf("Predict(%q) after reload = %q (%.3f), want %q (%.3f)", text, got, conf, want, wantConf)
+		}
+	}
+	if got, _ := c.Predict("手冲咖啡器具推荐"); got != "coffee" {
+		t.Errorf("Predict after reload = %q, want coffee", got)
+	}
+}
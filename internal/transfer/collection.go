@@ -0,0 +1,179 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+)
+
+// SortOrder 是专题、文集文章列表的排序方式，取值即站点的 order_by 参数
+type SortOrder string
+
+const (
+	SortNewest    SortOrder = "added_at"     //最新收录
+	SortCommented SortOrder = "commented_at" //最新评论
+	SortHottest   SortOrder = "top"          //热门
+)
+
+// Collection 是专题（/c/<id>）
+type Collection struct {
+	Id          string
+	Title       string
+	Description string
+	Owner       string //专题主编
+	OwnerSlug   string
+	Count       int //收录文章数
+	Followers   int //关注人数
+
+	ArticleList []*Article
+}
+
+// Notebook 是文集（/nb/<id>）
+type Notebook struct {
+	Id         string
+	Title      string
+	Author     string
+	AuthorSlug string
+	Count      int //文章数
+	Words      int //字数
+	Followers  int //关注人数
+
+	ArticleList []*Article
+}
+
+// ParseCollection 解析专题页的html，ArticleList 中只包含第一页的文章
+func ParseCollection(body string) (*Collection, error) {
+	doc, err := parseHTML(body)
+	if err != nil {
+		return nil, err
+	}
+	top := findFirst(doc, byClass("main-top"))
+	if top == nil {
+		return nil, fmt.Errorf("专题页中没有找到 main-top")
+	}
+
+	var c Collection
+	c.Title = text(findFirst(top, byClass("name")))
+	//“收录了 1234 篇文章 · 5678 人关注”
+	info := text(findFirst(top, byClass("info")))
+	c.Count, c.Followers = countsOf(info, "篇", "人")
+
+	if desc := findFirst(doc, byClass("description")); desc != nil {
+		c.Description = text(desc)
+	}
+	if owner := findFirst(doc, byClass("collection-editor")); owner != nil {
+		if a := userLink(owner); a != nil {
+			c.Owner = text(a)
+			c.OwnerSlug = slugOf(attr(a, "href"), "/u/")
+		}
+	}
+
+	c.ArticleList = ownArticles(doc)
+	return &c, nil
+}
+
+// ParseNotebook 解析文集页的html，ArticleList 中只包含第一页的文章
+func ParseNotebook(body string) (*Notebook, error) {
+	doc, err := parseHTML(body)
+	if err != nil {
+		return nil, err
+	}
+	top := findFirst(doc, byClass("main-top"))
+	if top == nil {
+		return nil, fmt.Errorf("文集页中没有找到 main-top")
+	}
+
+	var nb Notebook
+	nb.Title = text(findFirst(top, byClass("name")))
+	//“1 篇文章 · 2345 字 · 67 人关注”
+	info := text(findFirst(top, byClass("info")))
+	nb.Count, _ = countsOf(info, "篇", "")
+	nb.Words, nb.Followers = countsOf(info, "字", "人")
+
+	if a := userLink(top); a != nil {
+		nb.Author = text(a)
+		nb.AuthorSlug = slugOf(attr(a, "href"), "/u/")
+	}
+
+	nb.ArticleList = ownArticles(doc)
+	return &nb, nil
+}
+
+// userLink 返回第一个有文字的作者链接，头像链接里只有图片，跳过
+func userLink(n *html.Node) *html.Node {
+	return findFirst(n, func(n *html.Node) bool {
+		return isElement(n, "a") && strings.Contains(attr(n, "href"), "/u/") && text(n) != ""
+	})
+}
+
+// countsOf 取出紧挨着 unit1、unit2 之前的数字，unit 为空时对应的结果为 0
+func countsOf(info, unit1, unit2 string) (n1, n2 int) {
+	before := func(unit string) int {
+		if unit == "" {
+			return 0
+		}
+		i := strings.Index(info, unit)
+		if i < 0 {
+			return 0
+		}
+		s := strings.TrimRight(info[:i], " ")
+		j := strings.LastIndexFunc(s, func(r rune) bool {
+			return !(r >= '0' && r <= '9' || r == ',' || r == '.' || r == '万' || r == '亿')
+		})
+		if j >= 0 {
+			_, size := utf8.DecodeRuneInString(s[j:])
+			s = s[j+size:]
+		}
+		return parseCount(s)
+	}
+	return before(unit1), before(unit2)
+}
+
+// FetchCollection 抓取专题信息，并按 order 翻页取得全部文章
+func FetchCollection(httpClient *http.Client, id string, order SortOrder) (*Collection, error) {
+	if s := slugOf(id, "/c/"); s != "" {
+		id = s
+	}
+	url := JianShuHost + "/c/" + id
+	first := fmt.Sprintf("%s?order_by=%s", url, order)
+	body, err := fetchPage(httpClient, first)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCollection(body)
+	if err != nil {
+		return nil, err
+	}
+	c.Id = id
+
+	c.ArticleList, err = crawlArticlePages(httpClient, c.ArticleList, func(page int) string {
+		return fmt.Sprintf("%s&page=%d", first, page)
+	})
+	return c, err
+}
+
+// FetchNotebook 抓取文集信息，并按 order 翻页取得全部文章
+func FetchNotebook(httpClient *http.Client, id string, order SortOrder) (*Notebook, error) {
+	if s := slugOf(id, "/nb/"); s != "" {
+		id = s
+	}
+	url := JianShuHost + "/nb/" + id
+	first := fmt.Sprintf("%s?order_by=%s", url, order)
+	body, err := fetchPage(httpClient, first)
+	if err != nil {
+		return nil, err
+	}
+	nb, err := ParseNotebook(body)
+	if err != nil {
+		return nil, err
+	}
+	nb.Id = id
+
+	nb.ArticleList, err = crawlArticlePages(httpClient, nb.ArticleList, func(page int) string {
+		return fmt.Sprintf("%s&page=%d", first, page)
+	})
+	return nb, err
+}
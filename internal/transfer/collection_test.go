@@ -0,0 +1,50 @@
+package transfer
+
+import "testing"
+
+const collectionFixture = `<html><body>
+<div class="main-top">
+  <a class="avatar-collection" href="/c/V2CqjW"><img src="//upload.jianshu.io/collections/1.png"></a>
+  <div class="title"><a class="name" href="/c/V2CqjW">读书</a></div>
+  <div class="info">收录了1,234篇文章 · 5.6万人关注</div>
+</div>
+<div class="description">书籍是人类进步的阶梯</div>
+<div class="collection-editor"><a class="avatar" href="/u/9e5e9ab7b2ad"><img src="x.jpg"></a><a class="name" href="/u/9e5e9ab7b2ad">简书编辑</a></div>
+<ul class="note-list">
+<li data-note-id="1"><div class="content"><a class="title" href="/p/aaaaaaaaaaaa">第一篇</a><p class="abstract">摘要一</p></div></li>
+</ul>
+</body></html>`
+
+const notebookFixture = `<html><body>
+<div class="main-top">
+  <div class="title"><a class="name" href="/nb/123">随笔</a></div>
+  <div class="info">12 篇文章 · 34,567 字 · 89 人关注</div>
+  <a class="author" href="/u/4a4eb4feee62">采铜</a>
+</div>
+<ul class="note-list"></ul>
+</body></html>`
+
+func TestParseCollection(t *testing.T) {
+	c, err := ParseCollection(collectionFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Title != "读书" || c.Description != "书籍是人类进步的阶梯" || c.Owner != "简书编辑" || c.OwnerSlug != "9e5e9ab7b2ad" {
+		t.Errorf("got %+v", *c)
+	}
+	if c.Count != 1234 || c.Followers != 56000 {
+		t.Errorf("count = %d followers = %d, want 1234 56000", c.Count, c.Followers)
+	}
+	if len(c.ArticleList) != 1 || c.ArticleList[0].Title != "第一篇" {
+		t.Errorf("article list = %+v", c.ArticleList)
+	}
+
+	nb, err := ParseNotebook(notebookFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.Title != "随笔" || nb.Author != "采铜" || nb.AuthorSlug != "4a4eb4feee62" ||
+		nb.Count != 12 || nb.Words != 34567 || nb.Followers != 89 {
+		t.Errorf("got %+v", *nb)
+	}
+}
@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// Comment 是文章下的一条评论，Replies 中是针对它的回复，回复的回复继续嵌套
+type Comment struct {
+	Id         int64
+	ParentId   int64 //回复所针对的评论，顶层评论为 0
+	Floor      int   //楼层，回复没有楼层
+	Content    string
+	Text       string //去掉html标签后的内容
+	Author     string
+	AuthorSlug string
+	CreatedAt  string //页面原文，如 "2018-03-20T10:12:00.000+08:00"
+	Likes      int
+	Replies    []*Comment
+}
+
+// 评论接口 /notes/<note_id>/comments 的返回
+type commentPage struct {
+	Comments     []commentJSON `json:"comments"`
+	CommentCount int           `json:"comment_count"`
+	Page         int           `json:"page"`
+	TotalPages   int           `json:"total_pages"`
+}
+
+type commentJSON struct {
+	Id         int64  `json:"id"`
+	ParentId   int64  `json:"parent_id"`
+	Floor      int    `json:"floor"`
+	Content    string `json:"content"`
+	CreatedAt  string `json:"created_at"`
+	LikesCount int    `json:"likes_count"`
+	User       struct {
+		Slug     string `json:"slug"`
+		Nickname string `json:"nickname"`
+	} `json:"user"`
+	Children []commentJSON `json:"children"`
+}
+
+// FetchComments 按楼层顺序翻页取得一篇文章的全部评论；noteId 是 Article.NoteId
+func FetchComments(httpClient *http.Client, noteId string) ([]*Comment, error) {
+	if noteId == "" {
+		return nil, fmt.Errorf("缺少文章的 note id")
+	}
+
+	comments := make([]*Comment, 0)
+	for page := 1; page <= maxListPages; page++ {
+		req := newJSONRequest(http.MethodGet, JianShuHost+"/notes/"+noteId+"/comments").
+			SetHeader(`Referer`, JianShuHost+"/").
+			AddParam("comment_id", "").
+			AddParam("author_only", "false").
+			AddParam("since_id", "0").
+			AddParam("order_by", "asc").
+			AddParam("page", strconv.Itoa(page))
+
+		var cp commentPage
+		if err := fetchJSON(httpClient, req, &cp); err != nil {
+			return comments, err
+		}
+		for i := range cp.Comments {
+			comments = append(comments, newCommentTree(&cp.Comments[i]))
+		}
+		if len(cp.Comments) == 0 || page >= cp.TotalPages {
+			break
+		}
+	}
+	return comments, nil
+}
+
+// FetchArticleComments 取得文章的全部评论，并把评论总数（含回复）写回 a.Comment
+func FetchArticleComments(httpClient *http.Client, a *Article) ([]*Comment, error) {
+	comments, err := FetchComments(httpClient, a.NoteId)
+	if err != nil {
+		return comments, err
+	}
+	a.Comment = CountComments(comments)
+	a.CommentRaw = strconv.Itoa(a.Comment)
+	return comments, nil
+}
+
+// CountComments 返回评论及其全部回复的条数
+func CountComments(comments []*Comment) int {
+	n := 0
+	for _, c := range comments {
+		n += 1 + CountComments(c.Replies)
+	}
+	return n
+}
+
+// newCommentTree 把接口中平铺在 children 里的回复按 parent_id 组织成树
+func newCommentTree(cj *commentJSON) *Comment {
+	root := newComment(cj)
+	byId := map[int64]*Comment{root.Id: root}
+	for i := range cj.Children {
+		c := newComment(&cj.Children[i])
+		byId[c.Id] = c
+	}
+	for i := range cj.Children {
+		c := byId[cj.Children[i].Id]
+		parent, ok := byId[c.ParentId]
+		if !ok || parent == c {
+			parent = root
+		}
+		parent.Replies = append(parent.Replies, c)
+	}
+	return root
+}
+
+func newComment(cj *commentJSON) *Comment {
+	c := &Comment{
+		Id:         cj.Id,
+		ParentId:   cj.ParentId,
+		Floor:      cj.Floor,
+		Content:    cj.Content,
+		Author:     cj.User.Nickname,
+		AuthorSlug: cj.User.Slug,
+		CreatedAt:  cj.CreatedAt,
+		Likes:      cj.LikesCount,
+	}
+	if doc, err := parseHTML(cj.Content); err == nil {
+		c.Text = plainText(doc)
+	}
+	return c
+}
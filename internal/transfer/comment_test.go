@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const commentFixture = `{"comment_count":5,"page":1,"total_pages":1,"comments":[
+{"id":100,"floor":1,"content":"写得<b>好</b>","created_at":"2018-03-20T10:12:00.000+08:00","likes_count":3,
+ "user":{"slug":"u1","nickname":"甲"},
+ "children":[
+  {"id":102,"parent_id":101,"content":"回复乙","user":{"slug":"u1","nickname":"甲"}},
+  {"id":101,"parent_id":100,"content":"同意","user":{"slug":"u2","nickname":"乙"}},
+  {"id":103,"parent_id":999,"content":"找不到上级","user":{"slug":"u3","nickname":"丙"}}
+ ]},
+{"id":200,"floor":2,"content":"第二楼","user":{"slug":"u4","nickname":"丁"},"children":[]}
+]}`
+
+func TestCommentTree(t *testing.T) {
+	var cp commentPage
+	if err := json.Unmarshal([]byte(commentFixture), &cp); err != nil {
+		t.Fatal(err)
+	}
+	var comments []*Comment
+	for i := range cp.Comments {
+		comments = append(comments, newCommentTree(&cp.Comments[i]))
+	}
+	if n := CountComments(comments); n != 5 {
+		t.Errorf("CountComments = %d, want 5", n)
+	}
+
+	top := comments[0]
+	if top.Floor != 1 || top.Author != "甲" || top.Text != "写得好" || top.Likes != 3 {
+		t.Errorf("top comment = %+v", *top)
+	}
+	//101 回复楼主，102 回复 101，找不到上级的 103 挂在楼主下面
+	if len(top.Replies) != 2 || top.Replies[0].Id != 101 || top.Replies[1].Id != 103 {
+		t.Fatalf("replies of 100 = %+v", top.Replies)
+	}
+	if r := top.Replies[0].Replies; len(r) != 1 || r[0].Id != 102 || r[0].Author != "甲" {
+		t.Errorf("replies of 101 = %+v", r)
+	}
+	if len(comments[1].Replies) != 0 {
+		t.Errorf("replies of 200 = %+v", comments[1].Replies)
+	}
+}
@@ -0,0 +1,261 @@
+package transfer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 转载、洗稿的文章会大量出现在抓取结果中。Deduper 先按 slug 和正文内容的 sha1 做精确去重，
+// 再用正文的 SimHash 找出近似重复。中文没有空格分词，这里取连续的 N 个字符作为特征
+
+// Deduper 收集文章并把疑似重复的文章聚成簇
+type Deduper struct {
+	// Shingle 特征的字符数，默认 2。中文文章不长，特征取短一些相似度更稳定
+	Shingle int
+	// MinRunes 正文少于该字数时只做精确去重，太短的文本 SimHash 不可靠，默认 50
+	MinRunes int
+
+	threshold int //汉明距离的阈值，SimHash 分成 threshold+1 段建索引
+	docs      []*dedupDoc
+	bySlug    map[string]int
+	byHash    map[string]int
+	bands     []map[uint64][]int
+	parent    []int
+}
+
+type dedupDoc struct {
+	detail  *ArticleDetail
+	hash    string
+	simhash uint64 //正文太短时为 0
+	seenAt  time.Time
+}
+
+// DupCluster 是一组疑似重复的文章，按首次出现的时间排序，第一篇视为原文
+type DupCluster struct {
+	Articles []*ArticleDetail
+	SimHash  []uint64 //与 Articles 一一对应
+}
+
+// Original 返回簇中最早出现的文章
+func (c *DupCluster) Original() *ArticleDetail {
+	return c.Articles[0]
+}
+
+// NewDeduper 返回 Deduper，两篇文章 SimHash 的汉明距离不超过 threshold 即视为近似重复，通常取 5。
+// threshold 须在 [0, 63] 之间：SimHash 要分成 threshold+1 段，每段至少一位
+func NewDeduper(threshold int) (*Deduper, error) {
+	if threshold < 0 || threshold > 63 {
+		return nil, fmt.Errorf("近似重复的汉明距离阈值 %d 不在 [0, 63] 之间", threshold)
+	}
+	return &Deduper{Shingle: 2, MinRunes: 50, threshold: threshold}, nil
+}
+
+// Add 加入一篇文章，返回与它重复的、之前已加入的文章，没有时返回 nil。
+// 首次出现的时间取 PublishTime，为零值时取加入的时间
+func (dd *Deduper) Add(d *ArticleDetail) *ArticleDetail {
+	if dd.bySlug == nil {
+		dd.bySlug = make(map[string]int)
+		dd.byHash = make(map[string]int)
+	}
+	if dd.bands == nil {
+		dd.bands = make([]map[uint64][]int, dd.threshold+1)
+		for i := range dd.bands {
+			dd.bands[i] = make(map[uint64][]int)
+		}
+	}
+
+	norm := normalizeText(articleText(d))
+	sum := sha1.Sum([]byte(norm))
+	doc := &dedupDoc{detail: d, hash: hex.EncodeToString(sum[:]), seenAt: d.PublishTime}
+	if doc.seenAt.IsZero() {
+		doc.seenAt = time.Now()
+	}
+	id := len(dd.docs)
+	dd.docs = append(dd.docs, doc)
+	dd.parent = append(dd.parent, id)
+
+	dup := -1
+	slug := d.Slug
+	if slug == "" {
+		slug = slugOf(d.Url, "/p/")
+	}
+	if slug == "" && d.Url != "" {
+		slug = canonicalURL(d.Url)
+	}
+	if slug != "" {
+		if i, ok := dd.bySlug[slug]; ok {
+			dup = i
+		} else {
+			dd.bySlug[slug] = id
+		}
+	}
+	if norm != "" {
+		if i, ok := dd.byHash[doc.hash]; ok {
+			if dup < 0 {
+				dup = i
+			}
+			dd.union(i, id)
+		} else {
+			dd.byHash[doc.hash] = id
+		}
+	}
+	if dup >= 0 {
+		dd.union(dup, id)
+	}
+
+	if runes := []rune(norm); len(runes) >= dd.MinRunes {
+		doc.simhash = SimHash(runes, dd.Shingle)
+		//汉明距离不超过 threshold 时，把 64 位分成 threshold+1 段，至少有一段完全相同，
+		//只需比较某一段相同的文章
+		for b, key := range dd.bandKeys(doc.simhash) {
+			for _, j := range dd.bands[b][key] {
+				if bits.OnesCount64(doc.simhash^dd.docs[j].simhash) <= dd.threshold {
+					if dup < 0 {
+						dup = j
+					}
+					dd.union(j, id)
+				}
+			}
+			dd.bands[b][key] = append(dd.bands[b][key], id)
+		}
+	}
+
+	if dup < 0 {
+		return nil
+	}
+	return dd.docs[dup].detail
+}
+
+// Clusters 返回包含两篇及以上文章的簇，按簇中最早一篇的出现时间排序
+func (dd *Deduper) Clusters() []*DupCluster {
+	groups := make(map[int][]int)
+	for i := range dd.docs {
+		r := dd.find(i)
+		groups[r] = append(groups[r], i)
+	}
+
+	var sorted [][]int
+	for _, ids := range groups {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Slice(ids, func(a, b int) bool {
+			return dd.before(ids[a], ids[b])
+		})
+		sorted = append(sorted, ids)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return dd.before(sorted[a][0], sorted[b][0])
+	})
+
+	clusters := make([]*DupCluster, len(sorted))
+	for k, ids := range sorted {
+		c := &DupCluster{}
+		for _, i := range ids {
+			c.Articles = append(c.Articles, dd.docs[i].detail)
+			c.SimHash = append(c.SimHash, dd.docs[i].simhash)
+		}
+		clusters[k] = c
+	}
+	return clusters
+}
+
+// before 比较两篇文章首次出现的先后，时间相同时先加入的在前
+func (dd *Deduper) before(i, j int) bool {
+	if !dd.docs[i].seenAt.Equal(dd.docs[j].seenAt) {
+		return dd.docs[i].seenAt.Before(dd.docs[j].seenAt)
+	}
+	return i < j
+}
+
+func (dd *Deduper) bandKeys(h uint64) []uint64 {
+	n := len(dd.bands)
+	width := 64 / n
+	keys := make([]uint64, n)
+	for i := range keys {
+		shift := uint(i * width)
+		w := width
+		if i == n-1 {
+			w = 64 - i*width
+		}
+		keys[i] = h >> shift & (1<<uint(w) - 1)
+	}
+	return keys
+}
+
+func (dd *Deduper) find(i int) int {
+	for dd.parent[i] != i {
+		dd.parent[i] = dd.parent[dd.parent[i]]
+		i = dd.parent[i]
+	}
+	return i
+}
+
+func (dd *Deduper) union(i, j int) {
+	ri, rj := dd.find(i), dd.find(j)
+	if ri == rj {
+		return
+	}
+	//根保留先出现的文章，便于排查
+	if dd.before(rj, ri) {
+		ri, rj = rj, ri
+	}
+	dd.parent[rj] = ri
+}
+
+// SimHash 计算文本的 64 位 SimHash，特征为连续 n 个字符，出现多次的特征权重更高
+func SimHash(runes []rune, n int) uint64 {
+	if n <= 0 {
+		n = 2
+	}
+	if len(runes) < n {
+		n = len(runes)
+	}
+	var v [64]int
+	h := fnv.New64a()
+	for i := 0; i+n <= len(runes); i++ {
+		h.Reset()
+		h.Write([]byte(string(runes[i : i+n])))
+		x := h.Sum64()
+		for b := uint(0); b < 64; b++ {
+			if x&(1<<b) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+	var s uint64
+	for b := uint(0); b < 64; b++ {
+		if v[b] > 0 {
+			s |= 1 << b
+		}
+	}
+	return s
+}
+
+// articleText 取正文纯文本，没有正文时退回标题和摘要
+func articleText(d *ArticleDetail) string {
+	if d.BodyText != "" {
+		return d.BodyText
+	}
+	return d.Title + d.Abstract
+}
+
+// normalizeText 只保留文字和数字并转为小写，去掉标点、空白，
+// 这样改了标点、换行或加了几个空格的转载仍然能精确匹配
+func normalizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,71 @@
+package transfer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeduper(t *testing.T) {
+	body := "那年冬天，我第一次一个人坐火车去北方。车厢里挤满了回家过年的人，空气里混着泡面和橘子的味道。" +
+		"对面坐着一位老人，他一路都在看窗外，偶尔低头翻一翻手里那本已经发黄的笔记本。天快黑的时候，" +
+		"他忽然问我去哪里，我说去找一份工作。他笑了笑，说年轻真好，想去哪里就能去哪里。后来我们聊了很久，" +
+		"聊他年轻时在工厂里当学徒，聊他后来怎样学会修收音机，又怎样在小镇上开了一家修理铺。火车到站以前，" +
+		"他把那本笔记本送给了我，里面密密麻麻记着各种电路图和零件的价格。很多年过去了，我换过好几份工作，" +
+		"搬过好几次家，那本笔记本却一直放在书架最显眼的地方。每次看到它，我都会想起那个冬天的夜晚，" +
+		"想起车窗外飞快掠过的灯火，想起一个陌生人对另一个陌生人毫无保留的善意。"
+	day := func(d int) time.Time { return time.Date(2018, 3, d, 0, 0, 0, 0, chinaTime) }
+	art := func(slug, text string, d int) *ArticleDetail {
+		a := &ArticleDetail{Slug: slug, BodyText: text}
+		a.Url = "/p/" + slug
+		a.PublishTime = day(d)
+		return a
+	}
+
+	orig := art("a1", body, 1)
+	repost := art("b2", strings.Replace(body, "，", ", ", -1), 3) //只改了标点，正文 hash 相同
+	edited := art("c3", body+"转载请注明出处。本文有删改。", 2)                //少量改动
+	again := art("a1", "", 5)                                    //同一篇又抓了一次
+	other := art("d4", "周末整理了一下这几年用过的效率工具，从待办清单到笔记软件，从番茄钟到日历同步，"+
+		"每一样都曾经让我以为找到了提高效率的秘诀。可真正让我改变的，并不是工具本身，而是每天早上花十分钟"+
+		"想清楚今天最重要的三件事。工具只是放大器，它会放大你的条理，也会放大你的混乱。如果你也常常觉得"+
+		"一天忙忙碌碌却什么都没做成，不妨先放下手机，拿出一张纸，写下今天真正想完成的事情。", 4)
+
+	dd, err := NewDeduper(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []*ArticleDetail{repost, edited, orig, again, other} {
+		dd.Add(a)
+	}
+	clusters := dd.Clusters()
+	if len(clusters) != 1 {
+		t.Fatalf("got %d clusters, want 1", len(clusters))
+	}
+	var got []string
+	for _, a := range clusters[0].Articles {
+		got = append(got, a.Slug)
+	}
+	if strings.Join(got, ",") != "a1,c3,b2,a1" {
+		t.Errorf("cluster = %v, want [a1 c3 b2 a1]", got)
+	}
+}
+
+func TestNewDeduperThreshold(t *testing.T) {
+	for _, threshold := range []int{-1, 64, 100} {
+		if _, err := NewDeduper(threshold); err == nil {
+			t.Errorf("NewDeduper(%d) succeeded", threshold)
+		}
+	}
+	//阈值为 63 时每段只有一位，仍能正常加入文章
+	dd, err := NewDeduper(63)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &ArticleDetail{Slug: "a1", BodyText: strings.Repeat("简书", 40)}
+	b := &ArticleDetail{Slug: "b2", BodyText: strings.Repeat("简书", 40) + "。"}
+	dd.Add(a)
+	if dup := dd.Add(b); dup != a {
+		t.Errorf("Add = %v, want the first article", dup)
+	}
+}
@@ -0,0 +1,179 @@
+package transfer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+)
+
+var ErrNoArticleBody = errors.New("文章页中没有找到正文")
+
+// ArticleDetail 是文章详情页（/p/<slug>）的完整内容。
+// 发布时间 PublishTime、PublishTimeRaw 在嵌入的 Article 中
+type ArticleDetail struct {
+	Article
+	Slug       string
+	AuthorSlug string
+	WordCount  int //字数
+	Likes      int //喜欢
+	Rewards    int //赞赏
+	Tags       []string
+	BodyHtml   string
+	BodyText   string
+	//LocalImages 是 Localize 改写到 BodyHtml 中的本地图片路径，ToMarkdown 不按文章地址补全它们
+	LocalImages []string
+}
+
+// FetchArticleDetail 通过 Article.Url（/p/<slug>）抓取并解析文章详情，
+// 与 FetchDetail 一样按地址所属站点注册的规则解析，站内相对地址视为简书的地址
+func FetchArticleDetail(httpClient *http.Client, url string) (*ArticleDetail, error) {
+	d, err := FetchDetail(httpClient, absURL(url))
+	if err != nil {
+		return nil, err
+	}
+	if d.Slug == "" {
+		d.Slug = slugOf(d.Url, "/p/")
+	}
+	return d, nil
+}
+
+// ParseArticleDetail 从文章详情页的html中解析出标题、作者、计数和正文。
+// 优先使用页面内嵌的 json 数据（__NEXT_DATA__ 等），没有时再从html中抓取
+func ParseArticleDetail(body string) (*ArticleDetail, error) {
+	doc, err := parseHTML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if d := detailFromState(doc); d != nil {
+		if len(d.Tags) == 0 {
+			d.Tags = metaKeywords(doc)
+		}
+		return d, nil
+	}
+	d, err := detailFromHTML(doc)
+	if err != nil {
+		return nil, err
+	}
+	//旧版页面的正文只在html中，但文章id等信息在 page-data 里
+	if pd := pageDataOf(doc); pd != nil {
+		d.NoteId = strconv.FormatInt(pd.Note.Id, 10)
+		if d.AuthorSlug == "" {
+			d.AuthorSlug = pd.Note.Author.Slug
+		}
+		if d.Rewards == 0 {
+			d.Rewards = pd.Note.TotalRewardsCount
+		}
+	}
+	return d, nil
+}
+
+func detailFromHTML(doc *html.Node) (*ArticleDetail, error) {
+	content := findFirst(doc, byClass("show-content"))
+	if content == nil {
+		return nil, ErrNoArticleBody
+	}
+
+	var d ArticleDetail
+	article := findFirst(doc, byClass("article"))
+	if article == nil {
+		article = doc
+	}
+	d.Title = text(findFirst(article, byTagClass("h1", "title")))
+	if canonical := findFirst(doc, func(n *html.Node) bool {
+		return isElement(n, "link") && attr(n, "rel") == "canonical"
+	}); canonical != nil {
+		d.Url = pathOf(attr(canonical, "href"))
+		d.Slug = slugOf(d.Url, "/p/")
+	}
+
+	if author := findFirst(article, byClass("author")); author != nil {
+		if name := findFirst(author, byClass("name")); name != nil {
+			d.AUthor = text(name)
+			if a := findFirst(name, byTag("a")); a != nil {
+				d.AuthorSlug = slugOf(attr(a, "href"), "/u/")
+			}
+		}
+	}
+
+	setTime(&d.PublishTime, &d.PublishTimeRaw, text(findFirst(article, byClass("publish-time"))))
+	d.WordCount = parseCount(text(findFirst(article, byClass("wordage"))))
+	setCount(&d.Watched, &d.WatchedRaw, strings.TrimPrefix(text(findFirst(article, byClass("views-count"))), "阅读"))
+	setCount(&d.Comment, &d.CommentRaw, strings.TrimPrefix(text(findFirst(article, byClass("comments-count"))), "评论"))
+	setCount(&d.Collection, &d.CollectionRaw, strings.TrimPrefix(text(findFirst(article, byClass("likes-count"))), "喜欢"))
+	d.Likes = d.Collection
+	d.Rewards = parseCount(text(findFirst(article, byClass("rewards-count"))))
+
+	//文章被收入的专题作为标签，没有时退回到 <meta name="keywords">
+	for _, c := range findAll(doc, byClass("include-collection")) {
+		for _, name := range findAll(c, byClass("name")) {
+			if t := text(name); t != "" {
+				d.Tags = append(d.Tags, t)
+			}
+		}
+	}
+	if len(d.Tags) == 0 {
+		d.Tags = metaKeywords(doc)
+	}
+
+	d.BodyHtml = strings.TrimSpace(innerHTML(content))
+	d.BodyText = plainText(content)
+	d.Abstract = abstractOf(d.BodyText)
+
+	return &d, nil
+}
+
+func metaKeywords(doc *html.Node) (tags []string) {
+	kw := findFirst(doc, func(n *html.Node) bool {
+		return isElement(n, "meta") && attr(n, "name") == "keywords"
+	})
+	if kw == nil {
+		return nil
+	}
+	for _, t := range strings.Split(attr(kw, "content"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// slugOf 取出 /p/<slug>、/u/<slug> 这类地址中的 slug
+func slugOf(url, prefix string) string {
+	p := pathOf(url)
+	i := strings.Index(p, prefix)
+	if i < 0 {
+		return ""
+	}
+	s := p[i+len(prefix):]
+	if j := strings.IndexAny(s, "/?#"); j >= 0 {
+		s = s[:j]
+	}
+	return s
+}
+
+// pathOf 去掉地址中的协议和域名部分
+func pathOf(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+		if j := strings.Index(url, "/"); j >= 0 {
+			return url[j:]
+		}
+		return "/"
+	}
+	return url
+}
+
+// abstractOf 取正文的前若干个字符作为摘要
+func abstractOf(text string) string {
+	const n = 120
+	text = strings.Join(strings.Fields(text), " ")
+	r := []rune(text)
+	if len(r) <= n {
+		return text
+	}
+	return string(r[:n]) + "..."
+}
@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const detailFixture = `<html><head>
+<meta name="keywords" content="思维, 学习">
+<link rel="canonical" href="https://www.jianshu.com/p/6603d0ad230f">
+</head><body>
+<div class="article">
+  <h1 class="title">大脑版本升级：练习三个思维模型</h1>
+  <div class="author">
+    <div class="info">
+      <span class="name"><a href="/u/4a4eb4feee62">采铜</a></span>
+      <div class="meta">
+        <span class="publish-time">2018.03.20 10:12</span>
+        <span class="wordage">字数 3,562</span>
+        <span class="views-count">阅读 1.2万</span>
+        <span class="comments-count">评论 12</span>
+        <span class="likes-count">喜欢 120</span>
+        <span class="rewards-count">赞赏 3</span>
+      </div>
+    </div>
+  </div>
+  <div class="show-content"><p>第一段，<b>加粗</b>。</p><p>第二段</p></div>
+</div>
+<div class="include-collection"><a href="/c/1"><div class="name">心理</div></a><a href="/c/2"><div class="name">读书</div></a></div>
+</body></html>`
+
+func TestParseArticleDetail(t *testing.T) {
+	d, err := ParseArticleDetail(detailFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2018, 3, 20, 10, 12, 0, 0, chinaTime)
+	if d.Title != "大脑版本升级：练习三个思维模型" || d.AUthor != "采铜" || d.AuthorSlug != "4a4eb4feee62" {
+		t.Errorf("title/author = %q %q %q", d.Title, d.AUthor, d.AuthorSlug)
+	}
+	if d.Url != "/p/6603d0ad230f" || d.Slug != "6603d0ad230f" {
+		t.Errorf("url/slug = %q %q", d.Url, d.Slug)
+	}
+	if !d.PublishTime.Equal(published) || d.PublishTimeRaw != "2018.03.20 10:12" {
+		t.Errorf("publish time = %v (%q), want %v", d.PublishTime, d.PublishTimeRaw, published)
+	}
+	if d.WordCount != 3562 || d.Watched != 12000 || d.Comment != 12 || d.Likes != 120 || d.Rewards != 3 {
+		t.Errorf("counts = words %d watched %d comments %d likes %d rewards %d",
+			d.WordCount, d.Watched, d.Comment, d.Likes, d.Rewards)
+	}
+	if want := []string{"心理", "读书"}; !reflect.DeepEqual(d.Tags, want) {
+		t.Errorf("tags = %q, want %q", d.Tags, want)
+	}
+	if d.BodyHtml != "<p>第一段，<b>加粗</b>。</p><p>第二段</p>" {
+		t.Errorf("body html = %q", d.BodyHtml)
+	}
+	if d.BodyText != "第一段，加粗。\n第二段" {
+		t.Errorf("body text = %q", d.BodyText)
+	}
+
+	if _, err := ParseArticleDetail(`<html><body><h1 class="title">x</h1></body></html>`); err != ErrNoArticleBody {
+		t.Errorf("page without body: err = %v, want ErrNoArticleBody", err)
+	}
+}
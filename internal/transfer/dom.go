@@ -0,0 +1,183 @@
+package transfer
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// 基于 golang.org/x/net/html 的简单节点查找工具，避免依赖页面属性的书写顺序
+
+func parseHTML(body string) (*html.Node, error) {
+	return html.Parse(strings.NewReader(body))
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func isElement(n *html.Node, tag string) bool {
+	return n.Type == html.ElementNode && n.Data == tag
+}
+
+// findAll 返回 n 的所有满足 match 的后代节点（不含 n 本身），按文档顺序排列
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var nodes []*html.Node
+	var walk func(*html.Node)
+	walk = func(p *html.Node) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if match(c) {
+				nodes = append(nodes, c)
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return nodes
+}
+
+// findFirst 返回 n 的第一个满足 match 的后代节点，没有则返回 nil
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if match(c) {
+			return c
+		}
+		if f := findFirst(c, match); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+func byClass(class string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return hasClass(n, class) }
+}
+
+func byTagClass(tag, class string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return isElement(n, tag) && hasClass(n, class) }
+}
+
+func byTag(tag string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return isElement(n, tag) }
+}
+
+// text 返回节点下所有文本拼接后的内容，连续空白折叠为一个空格
+func text(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(p *html.Node) {
+		if p.Type == html.TextNode {
+			sb.WriteString(p.Data)
+			sb.WriteByte(' ')
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// innerHTML 渲染节点的全部子节点
+func innerHTML(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&sb, c)
+	}
+	return sb.String()
+}
+
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true, "blockquote": true,
+	"pre": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"table": true, "tr": true, "hr": true, "figure": true, "figcaption": true,
+}
+
+// plainText 与 text 类似，但在块级元素之间保留换行，用于提取正文纯文本
+func plainText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(p *html.Node) {
+		switch {
+		case p.Type == html.TextNode:
+			sb.WriteString(collapseSpace(p.Data))
+		case p.Type == html.ElementNode && (p.Data == "script" || p.Data == "style"):
+			return
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if p.Type == html.ElementNode && blockTags[p.Data] {
+			sb.WriteByte('\n')
+		}
+	}
+	walk(n)
+
+	lines := strings.Split(sb.String(), "\n")
+	out := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(collapseSpace(l)); l != "" {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// collapseSpace 把连续的空白字符折叠为一个空格，但保留首尾的空格
+func collapseSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	if space {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+// rawText 返回节点下文本的原样拼接，不折叠空白，用于读取 <script> 中的内容
+func rawText(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,161 @@
+package transfer
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// HomeFeed 按站点首页的“阅读更多”逐页读取文章，用法与 bufio.Scanner 相同：
+//
+//	feed := transfer.NewHomeFeed(httpClient)
+//	feed.MaxPages = 5
+//	for feed.Next() {
+//		for _, a := range feed.Articles() { ... }
+//	}
+//	if err := feed.Err(); err != nil { ... }
+type HomeFeed struct {
+	// MaxPages 最多读取的页数，0 表示不限制
+	MaxPages int
+	// Since 只保留发布时间不早于 Since 的文章，某一页全部早于 Since 时停止翻页；零值表示不限制
+	Since time.Time
+	// Health 不为空时检查第一页的解析质量，不达标时 Err 返回 *LayoutDriftError
+	Health *HealthCheck
+
+	httpClient *http.Client
+	site       SiteAdapter
+	page       int
+	seenIds    []string
+	seen       map[string]bool
+	articles   []*Article
+	done       bool
+	err        error
+}
+
+// NewHomeFeed 返回简书首页的 HomeFeed，用 RegisterAdapter 注册过的简书规则解析
+func NewHomeFeed(httpClient *http.Client) *HomeFeed {
+	site, err := AdapterFor(JianShuHost + "/")
+	if err != nil {
+		site = JianShu{}
+	}
+	return NewSiteFeed(httpClient, site)
+}
+
+// NewSiteFeed 返回从 site.HomeURL() 开始翻页、用 site 解析列表的 HomeFeed
+func NewSiteFeed(httpClient *http.Client, site SiteAdapter) *HomeFeed {
+	return &HomeFeed{
+		httpClient: httpClient,
+		site:       site,
+		seen:       make(map[string]bool),
+	}
+}
+
+// Next 读取下一页，返回 false 表示已经结束或出错，出错原因见 Err
+func (f *HomeFeed) Next() bool {
+	f.articles = nil
+	for !f.done {
+		if f.MaxPages > 0 && f.page >= f.MaxPages {
+			f.done = true
+			break
+		}
+		f.page++
+
+		body, err := f.fetch()
+		if err != nil {
+			f.err = err
+			f.done = true
+			break
+		}
+		list, err := f.site.ParseList(body)
+		if err != nil {
+			f.err = err
+			f.done = true
+			break
+		}
+		resolveArticles(f.site.HomeURL(), list)
+		if f.Health != nil && f.page == 1 {
+			if _, err := f.Health.Check("home", body, list); err != nil {
+				f.err = err
+				f.done = true
+				break
+			}
+		}
+		if len(list) == 0 {
+			f.done = true
+			break
+		}
+
+		fresh := 0
+		for _, a := range list {
+			key := a.NoteId
+			if key == "" {
+				key = canonicalURL(a.Url)
+			}
+			if f.seen[key] {
+				continue
+			}
+			f.seen[key] = true
+			fresh++
+			if a.NoteId != "" {
+				f.seenIds = append(f.seenIds, a.NoteId)
+			}
+			if f.before(a) {
+				continue
+			}
+			f.articles = append(f.articles, a)
+		}
+		//整页都是重复的，说明已经翻到头了；整页都早于 Since，也不必再往后翻
+		if fresh == 0 || len(f.articles) == 0 {
+			f.done = true
+		}
+		if len(f.articles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Articles 返回最近一次 Next 读到的新文章，已经出现过的文章不会重复返回
+func (f *HomeFeed) Articles() []*Article {
+	return f.articles
+}
+
+// Page 返回最近一次读取的页码，从 1 开始
+func (f *HomeFeed) Page() int {
+	return f.page
+}
+
+func (f *HomeFeed) Err() error {
+	return f.err
+}
+
+func (f *HomeFeed) fetch() (string, error) {
+	home := f.site.HomeURL()
+	if home == "" {
+		return "", fmt.Errorf("站点 %s 没有首页地址", f.site.Name())
+	}
+	if f.page == 1 {
+		return fetchPage(f.httpClient, home)
+	}
+	//后续页需要带上已经看过的文章id，服务端据此排除重复的推荐
+	req := newListRequest(home)
+	for _, id := range f.seenIds {
+		req.AddParam("seen_snote_ids[]", id)
+	}
+	req.AddParam("page", strconv.Itoa(f.page))
+
+	b, err := readBody(f.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (f *HomeFeed) before(a *Article) bool {
+	if f.Since.IsZero() || a.PublishTime.IsZero() {
+		return false
+	}
+	return a.PublishTime.Before(f.Since)
+}
@@ -0,0 +1,123 @@
+package transfer
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// feedPages 是测试首页每一页的文章id和发布日期（2018年3月的几号），第2页的开头与第1页重复
+var feedPages = map[string][][2]int{
+	"1": {{1, 20}, {2, 19}},
+	"2": {{2, 19}, {3, 18}},
+	"3": {{4, 10}, {5, 9}},
+}
+
+// feedServer 按 page 参数返回 feedPages 中的列表，记录每次请求的 page 和 seen_snote_ids[]
+type feedServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	s := &feedServer{}
+	s.Server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		page := r.FormValue("page")
+		if page == "" {
+			page = "1"
+		} else if r.Header.Get("X-INFINITESCROLL") != "true" {
+			t.Errorf("page %s requested without X-INFINITESCROLL", page)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, page+":"+strings.Join(r.Form["seen_snote_ids[]"], ","))
+		s.mu.Unlock()
+
+		fmt.Fprint(w, `<ul class="note-list">`)
+		for _, n := range feedPages[page] {
+			fmt.Fprintf(w, `<li data-note-id="%d"><div class="content"><a class="title" href="/p/note%d">第%d篇</a>`+
+				`<div class="meta"><span class="time" data-shared-at="2018-03-%02dT10:00:00+08:00"></span></div></div></li>`,
+				n[0], n[0], n[0], n[1])
+		}
+		fmt.Fprint(w, `</ul>`)
+	}))
+	return s
+}
+
+// readFeed 读完 f，返回每次 Next 得到的文章id
+func readFeed(t *testing.T, f *HomeFeed) (pages [][]string) {
+	for f.Next() {
+		var ids []string
+		for _, a := range f.Articles() {
+			ids = append(ids, a.NoteId)
+		}
+		pages = append(pages, ids)
+	}
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return pages
+}
+
+func TestHomeFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPages int
+		since    time.Time
+		want     [][]string
+		requests []string
+	}{
+		{
+			name:     "all pages",
+			want:     [][]string{{"1", "2"}, {"3"}, {"4", "5"}},
+			requests: []string{"1:", "2:1,2", "3:1,2,3", "4:1,2,3,4,5"},
+		},
+		{
+			name:     "max pages",
+			maxPages: 2,
+			want:     [][]string{{"1", "2"}, {"3"}},
+			requests: []string{"1:", "2:1,2"},
+		},
+		{
+			name:     "since",
+			since:    time.Date(2018, 3, 15, 0, 0, 0, 0, chinaTime),
+			want:     [][]string{{"1", "2"}, {"3"}},
+			requests: []string{"1:", "2:1,2", "3:1,2,3"},
+		},
+	}
+	for _, tt := range tests {
+		ts := newFeedServer(t)
+		f := NewSiteFeed(http.NewClient(), testSite{ts.URL + "/"})
+		f.MaxPages = tt.maxPages
+		f.Since = tt.since
+
+		if got := readFeed(t, f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: articles = %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(ts.requests, tt.requests) {
+			t.Errorf("%s: requests = %q, want %q", tt.name, ts.requests, tt.requests)
+		}
+		ts.Close()
+	}
+}
+
+func TestHomeFeedResolvesURLs(t *testing.T) {
+	ts := newFeedServer(t)
+	defer ts.Close()
+
+	f := NewSiteFeed(http.NewClient(), testSite{ts.URL + "/"})
+	f.MaxPages = 1
+	if !f.Next() {
+		t.Fatal(f.Err())
+	}
+	if want := ts.URL + "/p/note1"; f.Articles()[0].Url != want {
+		t.Errorf("Url = %q, want %q", f.Articles()[0].Url, want)
+	}
+}
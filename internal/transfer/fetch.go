@@ -0,0 +1,123 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/crawl"
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+const (
+	JianShuHost = "https://www.jianshu.com"
+
+	USER_AGENT  = crawl.DefaultUserAgent //与 robots.txt 检查用的是同一个身份
+	ACCEPT_TEXT = `text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8`
+	ACCEPT_JSON = `application/json, text/javascript, */*; q=0.01`
+)
+
+// absURL 把站内相对地址（如 /p/6603d0ad230f）补全为完整地址
+func absURL(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	if strings.HasPrefix(u, "/") {
+		return JianShuHost + u
+	}
+	return u
+}
+
+// resolveURL 把页面中的地址 ref 按页面本身的地址 base 补全，其他站点的相对地址不能用 absURL
+func resolveURL(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	b, err := http.Parse(base)
+	if err != nil || !b.IsAbs() {
+		return absURL(ref)
+	}
+	r, err := http.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// canonicalURL 返回地址的规范形式，用作去重的标识，无法解析时返回补全后的地址
+func canonicalURL(u string) string {
+	abs := absURL(u)
+	if c, err := crawl.Canonical(abs); err == nil {
+		return c
+	}
+	return abs
+}
+
+// newPageRequest 构造一个模拟浏览器访问页面的请求
+func newPageRequest(url string) *http.Request {
+	return http.NewRequest(http.MethodGet, absURL(url)).
+		SetHeader(`Accept`, ACCEPT_TEXT).
+		SetHeader(`Accept-Encoding`, `gzip, deflate`).
+		SetHeader(`Accept-Language`, `zh-CN,zh;q=0.9`).
+		SetHeader(`Connection`, `keep-alive`).
+		SetHeader(`Upgrade-Insecure-Requests`, `1`).
+		SetHeader(`User-Agent`, USER_AGENT)
+}
+
+// readBody 发送请求并读取响应体，非 2xx 的状态码视为错误
+func readBody(httpClient *http.Client, req *http.Request) ([]byte, error) {
+	resp, hcerr := req.SendBy(httpClient)
+	if hcerr != nil {
+		return nil, hcerr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return resp.BodyBytes()
+}
+
+// fetchPage 获取一个页面的html文本
+func fetchPage(httpClient *http.Client, url string) (string, error) {
+	b, err := readBody(httpClient, newPageRequest(url))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newListRequest 构造“加载更多”的分页请求，简书据此只返回列表片段
+func newListRequest(url string) *http.Request {
+	return newPageRequest(url).
+		SetHeader(`X-INFINITESCROLL`, `true`).
+		SetHeader(`X-Requested-With`, `XMLHttpRequest`)
+}
+
+// fetchListPage 获取分页列表中的一页html片段
+func fetchListPage(httpClient *http.Client, url string) (string, error) {
+	b, err := readBody(httpClient, newListRequest(url))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newJSONRequest 构造请求站内 json 接口的请求
+func newJSONRequest(method, url string) *http.Request {
+	return http.NewRequest(method, absURL(url)).
+		SetHeader(`Accept`, ACCEPT_JSON).
+		SetHeader(`Accept-Encoding`, `gzip, deflate`).
+		SetHeader(`Accept-Language`, `zh-CN,zh;q=0.9`).
+		SetHeader(`X-Requested-With`, `XMLHttpRequest`).
+		SetHeader(`User-Agent`, USER_AGENT)
+}
+
+// fetchJSON 发送请求并把响应的 json 解码到 v
+func fetchJSON(httpClient *http.Client, req *http.Request, v interface{}) error {
+	b, err := readBody(httpClient, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
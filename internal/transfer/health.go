@@ -0,0 +1,229 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 站点改版后解析往往不报错，只是返回空列表或大量空字段，看起来像是“今天没什么新文章”。
+// HealthCheck 在每次解析后检查结果的质量，不达标时返回 *LayoutDriftError 并保存原始html
+
+// HealthCheck 是解析质量检查的配置
+type HealthCheck struct {
+	// MinItems 一页至少应解析出的条目数
+	MinItems int
+	// RequiredFields 必填字段，名称与抽取规则中的字段名相同，如 "title"、"url"、"author"
+	RequiredFields []string
+	// MinFieldRatio 必填字段中非空的比例下限，0~1
+	MinFieldRatio float64
+	// MaxDrop 条目数相对历史平均值允许下降的比例，0~1，0 表示不与历史比较
+	MaxDrop float64
+	// HistoryFile 保存历史解析结果的 json 文件，为空时不与历史比较
+	HistoryFile string
+	// DumpDir 检查失败时保存原始html的目录，为空时不保存
+	DumpDir string
+
+	mu sync.Mutex
+}
+
+// historySize 每个页面保留的历史记录条数
+const historySize = 20
+
+// NewHealthCheck 返回一组常用的检查配置，历史记录和失败时的html都保存在 dir 下
+func NewHealthCheck(dir string) *HealthCheck {
+	return &HealthCheck{
+		MinItems:       1,
+		RequiredFields: []string{"title", "url", "author"},
+		MinFieldRatio:  0.8,
+		MaxDrop:        0.5,
+		HistoryFile:    filepath.Join(dir, "health.json"),
+		DumpDir:        dir,
+	}
+}
+
+// ParseHealth 是一次解析的统计结果
+type ParseHealth struct {
+	Page       string             `json:"page"`
+	Time       time.Time          `json:"time"`
+	Items      int                `json:"items"`
+	FieldRatio map[string]float64 `json:"field_ratio"` //每个必填字段非空的比例
+	Filled     float64            `json:"filled"`      //全部必填字段非空的比例
+}
+
+// LayoutDriftError 表示解析结果不达标，页面结构很可能已经变了
+type LayoutDriftError struct {
+	Health   *ParseHealth
+	Reasons  []string
+	DumpFile string //保存下来的原始html，未保存时为空
+}
+
+func (e *LayoutDriftError) Error() string {
+	msg := fmt.Sprintf("页面 %s 解析异常，可能已改版: %s", e.Health.Page, strings.Join(e.Reasons, "; "))
+	if e.DumpFile != "" {
+		msg += "，原始html已保存到 " + e.DumpFile
+	}
+	return msg
+}
+
+// Check 检查 page 页面的解析结果 arts，body 是解析所用的原始html。
+// 检查通过时结果会记入历史；不通过时返回 *LayoutDriftError
+func (hc *HealthCheck) Check(page, body string, arts []*Article) (*ParseHealth, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	h := Measure(page, arts, hc.RequiredFields)
+	var reasons []string
+	if h.Items < hc.MinItems {
+		reasons = append(reasons, fmt.Sprintf("只解析出 %d 条，至少应有 %d 条", h.Items, hc.MinItems))
+	}
+	if h.Items > 0 && h.Filled < hc.MinFieldRatio {
+		var empty []string
+		for _, f := range hc.RequiredFields {
+			if h.FieldRatio[f] < hc.MinFieldRatio {
+				empty = append(empty, fmt.Sprintf("%s(%.0f%%)", f, h.FieldRatio[f]*100))
+			}
+		}
+		reasons = append(reasons, fmt.Sprintf("必填字段填充率 %.0f%%，低于 %.0f%%: %s",
+			h.Filled*100, hc.MinFieldRatio*100, strings.Join(empty, ", ")))
+	}
+
+	history, err := hc.loadHistory()
+	if err != nil {
+		return h, err
+	}
+	if hc.MaxDrop > 0 {
+		if avg, n := averageItems(history[page]); n > 0 && float64(h.Items) < avg*(1-hc.MaxDrop) {
+			reasons = append(reasons, fmt.Sprintf("条目数 %d 比最近 %d 次的平均值 %.1f 下降了 %.0f%% 以上",
+				h.Items, n, avg, hc.MaxDrop*100))
+		}
+	}
+
+	if len(reasons) > 0 {
+		e := &LayoutDriftError{Health: h, Reasons: reasons}
+		if hc.DumpDir != "" {
+			if e.DumpFile, err = hc.dump(page, body); err != nil {
+				return h, err
+			}
+		}
+		return h, e
+	}
+
+	if hc.HistoryFile != "" {
+		records := append(history[page], h)
+		if len(records) > historySize {
+			records = records[len(records)-historySize:]
+		}
+		history[page] = records
+		if err := hc.saveHistory(history); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// Measure 统计解析结果中各必填字段的填充率
+func Measure(page string, arts []*Article, required []string) *ParseHealth {
+	h := &ParseHealth{
+		Page:       page,
+		Time:       time.Now(),
+		Items:      len(arts),
+		FieldRatio: make(map[string]float64),
+	}
+	if len(arts) == 0 || len(required) == 0 {
+		return h
+	}
+	total := 0
+	for _, f := range required {
+		n := 0
+		for _, a := range arts {
+			if strings.TrimSpace(articleField(a, f)) != "" {
+				n++
+			}
+		}
+		h.FieldRatio[f] = float64(n) / float64(len(arts))
+		total += n
+	}
+	h.Filled = float64(total) / float64(len(arts)*len(required))
+	return h
+}
+
+func articleField(a *Article, name string) string {
+	switch name {
+	case "note_id":
+		return a.NoteId
+	case "title":
+		return a.Title
+	case "author":
+		return a.AUthor
+	case "abstract":
+		return a.Abstract
+	case "url":
+		return a.Url
+	case "publish_time":
+		return a.PublishTimeRaw
+	case "watched":
+		return a.WatchedRaw
+	case "comment":
+		return a.CommentRaw
+	case "collection":
+		return a.CollectionRaw
+	}
+	return ""
+}
+
+func averageItems(records []*ParseHealth) (avg float64, n int) {
+	for _, r := range records {
+		avg += float64(r.Items)
+	}
+	if n = len(records); n > 0 {
+		avg /= float64(n)
+	}
+	return avg, n
+}
+
+func (hc *HealthCheck) loadHistory() (map[string][]*ParseHealth, error) {
+	history := make(map[string][]*ParseHealth)
+	if hc.HistoryFile == "" {
+		return history, nil
+	}
+	b, err := ioutil.ReadFile(hc.HistoryFile)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &history); err != nil {
+		return nil, fmt.Errorf("%s: %s", hc.HistoryFile, err)
+	}
+	return history, nil
+}
+
+func (hc *HealthCheck) saveHistory(history map[string][]*ParseHealth) error {
+	b, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(hc.HistoryFile), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(hc.HistoryFile, b, 0644)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (hc *HealthCheck) dump(page, body string) (string, error) {
+	if err := os.MkdirAll(hc.DumpDir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(page, "_"), "_")
+	file := filepath.Join(hc.DumpDir, fmt.Sprintf("%s-%s.html", name, time.Now().Format("20060102-150405")))
+	return file, ioutil.WriteFile(file, []byte(body), 0644)
+}
@@ -0,0 +1,41 @@
+package transfer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hc := NewHealthCheck(dir)
+
+	arts, _ := ParseArticles(homeFixture)
+	if _, err := hc.Check("home", homeFixture, arts); err != nil {
+		t.Fatalf("healthy page reported as drift: %s", err)
+	}
+
+	//改版后标题链接换了 class，解析结果为空
+	_, err = hc.Check("home", "<html></html>", nil)
+	drift, ok := err.(*LayoutDriftError)
+	if !ok {
+		t.Fatalf("got %v, want *LayoutDriftError", err)
+	}
+	if len(drift.Reasons) != 2 {
+		t.Errorf("want min-items and history reasons, got %q", drift.Reasons)
+	}
+	if filepath.Dir(drift.DumpFile) != dir {
+		t.Errorf("html not dumped into %s: %q", dir, drift.DumpFile)
+	}
+
+	//必填字段大量为空
+	_, err = hc.Check("home", homeFixture, []*Article{{Url: "/p/a"}, {Url: "/p/b"}})
+	if _, ok := err.(*LayoutDriftError); !ok {
+		t.Errorf("empty required fields not reported, got %v", err)
+	}
+}
@@ -1,37 +1,126 @@
 package transfer
 
 import (
-	"regexp"
+	"time"
 
 	"github.com/xiye518/crawjianshu/internal/tools/console/color"
+	"golang.org/x/net/html"
 )
 
+// ParseArticles 从简书首页（或同样使用 note-list 的列表页）的html中解析出文章列表。
+// 优先使用页面内嵌的 json 数据，其中没有文章时再从html中抓取
 func ParseArticles(body string) (arts []*Article, err error) {
 	arts = make([]*Article, 0)
-	//reg:=regexp.MustCompile(`(?s)<a class="title" target="_blank" href="(.+?)">(.+?)</a>`)
-	reg := regexp.MustCompile(`(?s)<a class="title" target="_blank" href="(.+?)">(.+?)</a>\s*<p class="abstract">\s*(.+?)</p>`)
-	result := reg.FindAllStringSubmatch(body, -1) //...		/p/6603d0ad230f	大脑版本升级：练习三个思维模型，一下午就能让你聪明起来  描述...
-	for _, r := range result {
-		var a Article
-		a.Url = r[1]
-		a.Title = r[2]
-		a.Abstract = r[3]
-		arts = append(arts, &a)
-	}
-
-	return arts, err
+	doc, err := parseHTML(body)
+	if err != nil {
+		return arts, err
+	}
+	if list := articlesFromState(doc); len(list) > 0 {
+		return list, nil
+	}
+	return articlesFromHTML(doc), nil
+}
+
+func articlesFromHTML(doc *html.Node) []*Article {
+	arts := make([]*Article, 0)
+	//每篇文章的标题链接都是 <a class="title" href="/p/6603d0ad230f">，以其所在的内容块为单位解析
+	for _, t := range findAll(doc, byTagClass("a", "title")) {
+		a := parseArticleItem(itemOf(t), t)
+		if a.Url == "" {
+			continue
+		}
+		arts = append(arts, a)
+	}
+	return arts
+}
+
+// ownArticles 解析作者主页、专题、文集页面自己的文章列表。这些页面的内嵌数据中还有推荐、侧栏等文章，
+// 分不清哪个数组是页面自己的，所以以html列表为准：内嵌数据只用来替换html列表中同一篇文章的数据，多出来的文章不要
+func ownArticles(doc *html.Node) []*Article {
+	arts := articlesFromHTML(doc)
+	bySlug := make(map[string]*Article)
+	for _, a := range articlesFromState(doc) {
+		bySlug[slugOf(a.Url, "/p/")] = a
+	}
+	for i, a := range arts {
+		if s := bySlug[slugOf(a.Url, "/p/")]; s != nil {
+			if s.NoteId == "" {
+				s.NoteId = a.NoteId
+			}
+			arts[i] = s
+		}
+	}
+	return arts
+}
+
+// itemOf 找到标题链接所属的文章块：优先 <li>，其次 class="content" 的 div
+func itemOf(title *html.Node) *html.Node {
+	var content *html.Node
+	for p := title.Parent; p != nil; p = p.Parent {
+		if isElement(p, "li") {
+			return p
+		}
+		if content == nil && hasClass(p, "content") {
+			content = p
+		}
+	}
+	if content != nil {
+		return content
+	}
+	return title.Parent
 }
 
+func parseArticleItem(item, title *html.Node) *Article {
+	var a Article
+	a.NoteId = attr(item, "data-note-id")
+	a.Url = attr(title, "href")
+	a.Title = text(title)
+	a.Abstract = text(findFirst(item, byClass("abstract")))
+	if nick := findFirst(item, byClass("nickname")); nick != nil {
+		a.AUthor = text(nick)
+	}
+	if t := findFirst(item, byClass("time")); t != nil {
+		setTime(&a.PublishTime, &a.PublishTimeRaw, attr(t, "data-shared-at"))
+	}
+
+	//meta 区域里的计数都以 <i class="iconfont ic-list-xxx"></i> 开头，数字紧跟在图标之后
+	for _, icon := range findAll(item, byTag("i")) {
+		switch {
+		case hasClass(icon, "ic-list-read"):
+			setCount(&a.Watched, &a.WatchedRaw, text(icon.Parent))
+		case hasClass(icon, "ic-list-comments"):
+			setCount(&a.Comment, &a.CommentRaw, text(icon.Parent))
+		case hasClass(icon, "ic-list-like"):
+			setCount(&a.Collection, &a.CollectionRaw, text(icon.Parent))
+		}
+	}
+
+	return &a
+}
+
+// Article 中的计数和时间都已解析，页面上的原文保存在对应的 Raw 字段中以便核对
 type Article struct {
-	Title      string
-	AUthor     string
-	Abstract   string
-	Url        string
-	Watched    string //已阅
-	Comment    string //点评数
-	Collection string //收藏数
+	NoteId      string //列表项上的 data-note-id
+	Title       string
+	AUthor      string
+	Abstract    string
+	Url         string
+	PublishTime time.Time //发布时间，无法解析时为零值
+	Watched     int       //已阅
+	Comment     int       //点评数
+	Collection  int       //收藏数（列表页上显示为“喜欢”）
+
+	PublishTimeRaw string //如 "2018-03-20T10:12:00+08:00"、"2018.03.20 10:12"、"3天前"
+	WatchedRaw     string //如 "1.2万"
+	CommentRaw     string
+	CollectionRaw  string
+
+	Stats         *TextStats //文本统计，由 Analyzer 填写，未分析时为 nil
+	Category      string     //分类，由 Classifier 填写
+	CategoryScore float64    //分类的置信度，0~1
 }
 
 func (a *Article) String(i int) {
-	color.LogAndPrintln(i, color.HiGreen(a.Title), "https://www.jianshu.com"+a.Url, a.Abstract)
+	color.LogAndPrintln(i, color.HiGreen(a.Title), a.Url, a.AUthor,
+		"阅读:", a.Watched, "评论:", a.Comment, "喜欢:", a.Collection, a.Abstract)
 }
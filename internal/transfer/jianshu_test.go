@@ -0,0 +1,67 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+)
+
+const homeFixture = `<html><body><ul class="note-list" infinite-scroll-url="/">
+<li id="note-26393040" data-note-id="26393040" class="have-img">
+  <a class="wrap-img" href="/p/6603d0ad230f" target="_blank"><img data-echo="//upload-images.jianshu.io/upload_images/1.jpg" alt="120" /></a>
+  <div class="content">
+    <a href="/p/6603d0ad230f" target="_blank" class="title">大脑版本升级：练习三个思维模型</a>
+    <p class="abstract">
+      一下午就能让你聪明起来
+    </p>
+    <div class="meta">
+      <span class="jsd-meta"><i class="iconfont ic-paid1"></i> 0.2</span>
+      <a class="nickname" target="_blank" href="/u/4a4eb4feee62">采铜</a>
+      <span class="time" data-shared-at="2018-03-20T10:12:00+08:00"></span>
+      <a target="_blank" href="/p/6603d0ad230f"><i class="iconfont ic-list-read"></i> 1.2万</a>
+      <a target="_blank" href="/p/6603d0ad230f#comments"><i class="iconfont ic-list-comments"></i> 12</a>
+      <span><i class="iconfont ic-list-like"></i> 120</span>
+    </div>
+  </div>
+</li>
+<li id="note-26393041" data-note-id="26393041" class="">
+  <div class="content">
+    <a class="title"
+       target="_blank"   href="/p/aaaaaaaaaaaa">第二篇</a>
+    <p class="abstract">摘要二</p>
+  </div>
+</li>
+</ul></body></html>`
+
+func TestParseArticles(t *testing.T) {
+	arts, err := ParseArticles(homeFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arts) != 2 {
+		t.Fatalf("got %d articles, want 2", len(arts))
+	}
+
+	a := arts[0]
+	published, _ := time.Parse(time.RFC3339, "2018-03-20T10:12:00+08:00")
+	want := Article{
+		NoteId:         "26393040",
+		Title:          "大脑版本升级：练习三个思维模型",
+		AUthor:         "采铜",
+		Abstract:       "一下午就能让你聪明起来",
+		Url:            "/p/6603d0ad230f",
+		PublishTime:    published,
+		Watched:        12000,
+		Comment:        12,
+		Collection:     120,
+		PublishTimeRaw: "2018-03-20T10:12:00+08:00",
+		WatchedRaw:     "1.2万",
+		CommentRaw:     "12",
+		CollectionRaw:  "120",
+	}
+	if *a != want {
+		t.Errorf("got %+v\nwant %+v", *a, want)
+	}
+	if arts[1].Url != "/p/aaaaaaaaaaaa" || arts[1].Title != "第二篇" {
+		t.Errorf("attribute order should not matter, got %+v", *arts[1])
+	}
+}
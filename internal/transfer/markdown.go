@@ -0,0 +1,348 @@
+package transfer
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+)
+
+// ToMarkdown 把文章详情转换为带 YAML front matter 的 CommonMark 文本，
+// 正文中的相对链接和图片地址按文章地址补全
+func ToMarkdown(d *ArticleDetail) (string, error) {
+	base, err := http.Parse(absURL(d.Url))
+	if err != nil {
+		return "", err
+	}
+	local := make(map[string]bool)
+	for _, file := range d.LocalImages {
+		local[file] = true
+	}
+	body, err := htmlToMarkdown(d.BodyHtml, base, local)
+	if err != nil {
+		return "", err
+	}
+	return FrontMatter(d) + "\n" + body, nil
+}
+
+// FrontMatter 生成文章元数据的 YAML front matter，以 --- 包裹
+func FrontMatter(d *ArticleDetail) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	field := func(key, value string) {
+		if value != "" {
+			sb.WriteString(key + ": " + yamlString(value) + "\n")
+		}
+	}
+	number := func(key string, value int) {
+		sb.WriteString(key + ": " + strconv.Itoa(value) + "\n")
+	}
+	field("title", d.Title)
+	field("author", d.AUthor)
+	field("author_slug", d.AuthorSlug)
+	field("slug", d.Slug)
+	field("url", absURL(d.Url))
+	if !d.PublishTime.IsZero() {
+		field("published", d.PublishTime.Format(time.RFC3339))
+	} else {
+		field("published", d.PublishTimeRaw)
+	}
+	number("word_count", d.WordCount)
+	number("views", d.Watched)
+	number("comments", d.Comment)
+	number("likes", d.Likes)
+	number("rewards", d.Rewards)
+	if len(d.Tags) > 0 {
+		sb.WriteString("tags:\n")
+		for _, t := range d.Tags {
+			sb.WriteString("  - " + yamlString(t) + "\n")
+		}
+	}
+	sb.WriteString("---\n")
+	return sb.String()
+}
+
+// yamlString 用双引号形式输出字符串，JSON 的字符串转义同样是合法的 YAML
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// HTMLToMarkdown 把一段文章正文html转换为 CommonMark，base 用于补全相对地址，可以为 nil
+func HTMLToMarkdown(body string, base *http.URL) (string, error) {
+	return htmlToMarkdown(body, base, nil)
+}
+
+// htmlToMarkdown 与 HTMLToMarkdown 相同，local 中的图片地址是本地路径，不再补全
+func htmlToMarkdown(body string, base *http.URL, local map[string]bool) (string, error) {
+	nodes, err := parseFragment(body)
+	if err != nil {
+		return "", err
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "div"}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	m := &mdConverter{base: base, local: local}
+	return strings.TrimSpace(m.blocks(root)) + "\n", nil
+}
+
+type mdConverter struct {
+	base  *http.URL
+	local map[string]bool
+}
+
+func (m *mdConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	if strings.HasPrefix(ref, "//") {
+		ref = "https:" + ref
+	}
+	if m.base == nil {
+		return ref
+	}
+	u, err := http.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return m.base.ResolveReference(u).String()
+}
+
+// imageSrc 简书的图片是懒加载的，真实地址在 data-original-src 中
+func imageSrc(n *html.Node) string {
+	for _, key := range []string{"data-original-src", "data-src", "data-echo", "src"} {
+		if v := attr(n, key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func isBlock(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.Data {
+	case "p", "div", "section", "article", "header", "footer", "figure", "figcaption",
+		"h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "blockquote", "pre", "hr", "table":
+		return true
+	}
+	return false
+}
+
+// blocks 把 n 的子节点渲染为以空行分隔的块
+func (m *mdConverter) blocks(n *html.Node) string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(inline.String()); s != "" {
+			out = append(out, s)
+		}
+		inline.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isBlock(c) {
+			inline.WriteString(m.inline(c))
+			continue
+		}
+		flush()
+		if s := m.block(c); s != "" {
+			out = append(out, s)
+		}
+	}
+	flush()
+
+	return strings.Join(out, "\n\n")
+}
+
+func (m *mdConverter) block(n *html.Node) string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + strings.TrimSpace(m.inlineChildren(n))
+	case "hr":
+		return "---"
+	case "pre":
+		return m.codeBlock(n)
+	case "blockquote":
+		return prefixLines(m.blocks(n), "> ", "> ")
+	case "ul", "ol":
+		return m.list(n)
+	}
+	return m.blocks(n)
+}
+
+func (m *mdConverter) list(n *html.Node) string {
+	var items []string
+	i := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		i = start
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isElement(c, "li") {
+			continue
+		}
+		marker := "- "
+		if n.Data == "ol" {
+			marker = strconv.Itoa(i) + ". "
+			i++
+		}
+		items = append(items, prefixLines(m.blocks(c), marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (m *mdConverter) codeBlock(n *html.Node) string {
+	code := n
+	if c := findFirst(n, byTag("code")); c != nil {
+		code = c
+	}
+	lang := ""
+	for _, class := range strings.Fields(attr(code, "class") + " " + attr(n, "class")) {
+		if strings.HasPrefix(class, "language-") {
+			lang = strings.TrimPrefix(class, "language-")
+			break
+		}
+	}
+
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(p *html.Node) {
+		if p.Type == html.TextNode {
+			sb.WriteString(p.Data)
+		} else if isElement(p, "br") {
+			sb.WriteByte('\n')
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(code)
+	src := strings.TrimRight(sb.String(), "\n")
+
+	fence := "```"
+	for strings.Contains(src, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + src + "\n" + fence
+}
+
+func (m *mdConverter) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(m.inline(c))
+	}
+	return sb.String()
+}
+
+func (m *mdConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(collapseSpace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "script", "style":
+		return ""
+	case "br":
+		return "  \n"
+	case "strong", "b":
+		return wrapInline(m.inlineChildren(n), "**")
+	case "em", "i":
+		return wrapInline(m.inlineChildren(n), "*")
+	case "del", "s":
+		return wrapInline(m.inlineChildren(n), "~~")
+	case "code":
+		t := text(n)
+		fence := "`"
+		for strings.Contains(t, fence) {
+			fence += "`"
+		}
+		return fence + t + fence
+	case "a":
+		label := strings.TrimSpace(m.inlineChildren(n))
+		href := m.resolve(attr(n, "href"))
+		if href == "" {
+			return label
+		}
+		if label == "" {
+			label = href
+		}
+		return "[" + label + "](" + mdDestination(href) + ")"
+	case "img":
+		src := imageSrc(n)
+		if !m.local[src] {
+			src = m.resolve(src)
+		}
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(attr(n, "alt")) + "](" + mdDestination(src) + ")"
+	}
+	return m.inlineChildren(n)
+}
+
+// wrapInline 给行内文本加上强调符号，首尾空格放在符号外面
+func wrapInline(s, mark string) string {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, t)]
+	trail := s[len(lead)+len(t):]
+	return lead + mark + t + mark + trail
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`,
+)
+
+func escapeMarkdown(s string) string {
+	s = markdownEscaper.Replace(s)
+	//行首的 # > - + 会被当作块级标记，1. 和 1) 会被当作有序列表
+	t := strings.TrimLeft(s, " ")
+	if t != "" && strings.ContainsRune("#>-+", rune(t[0])) {
+		return s[:len(s)-len(t)] + `\` + t
+	}
+	if i := strings.IndexFunc(t, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && i <= 9 &&
+		(t[i] == '.' || t[i] == ')') && (i+1 == len(t) || t[i+1] == ' ') {
+		return s[:len(s)-len(t)] + t[:i] + `\` + t[i:]
+	}
+	return s
+}
+
+// mdDestination 输出链接和图片的地址，含有空格或括号时用 <...> 包裹，其中的 < > 和换行百分号编码
+func mdDestination(u string) string {
+	if !strings.ContainsAny(u, " ()<>\t\r\n") {
+		return u
+	}
+	return "<" + destinationEscaper.Replace(u) + ">"
+}
+
+var destinationEscaper = strings.NewReplacer("<", "%3C", ">", "%3E", "\t", "%09", "\r", "%0D", "\n", "%0A")
+
+// prefixLines 给第一行加 first 前缀，其余非空行加 rest 前缀
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + l
+		case l == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
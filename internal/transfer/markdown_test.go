@@ -0,0 +1,40 @@
+package transfer
+
+import (
+	"testing"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := http.Parse("https://www.jianshu.com/p/6603d0ad230f")
+	tests := []struct {
+		name, in, want string
+	}{
+		{"heading", `<h2>小标题</h2><p>正文</p>`, "## 小标题\n\n正文"},
+		{"emphasis", `<p><b>粗</b> 和 <em>斜</em></p>`, "**粗** 和 *斜*"},
+		{"nested list", `<ul><li>一<ul><li>一.1</li></ul></li><li>二</li></ul>`, "- 一\n\n  - 一.1\n- 二"},
+		{"ordered list", `<ol start="3"><li>三</li><li>四</li></ol>`, "3. 三\n4. 四"},
+		{"blockquote", `<blockquote><p>引用一</p><p>引用二</p></blockquote>`, "> 引用一\n>\n> 引用二"},
+		{"code fence", "<pre><code class=\"language-go\">fmt.Println(\"```\")\n</code></pre>", "````go\nfmt.Println(\"```\")\n````"},
+		{"relative link", `<p><a href="/u/4a4eb4feee62">采铜</a></p>`, "[采铜](https://www.jianshu.com/u/4a4eb4feee62)"},
+		{"lazy image", `<img data-original-src="//upload-images.jianshu.io/a.jpg" src="" alt="图">`, "![图](https://upload-images.jianshu.io/a.jpg)"},
+		{"resolved destination", `<a href="/wiki/Go_(programming language)">Go</a>`, "[Go](https://www.jianshu.com/wiki/Go_%28programming%20language%29)"},
+		{"escape ordered marker", `<p>2018. 年度总结</p>`, `2018\. 年度总结`},
+		{"escape block marker", `<p># 不是标题 *也不是强调*</p>`, `\# 不是标题 \*也不是强调\*`},
+	}
+	for _, tt := range tests {
+		got, err := HTMLToMarkdown(tt.in, base)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want+"\n" {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want+"\n")
+		}
+	}
+	//没有 base 时地址原样输出
+	if got, _ := HTMLToMarkdown(`<img src="my images/a(1).jpg">`, nil); got != "![](<my images/a(1).jpg>)\n" {
+		t.Errorf("destination without base: got %q", got)
+	}
+}
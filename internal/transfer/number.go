@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 页面上的计数和时间都是给人看的：“1.2万”“3,024”“3天前”“昨天 10:12”“2018.03.20 10:12”。
+// 这里把它们转换为整数和 time.Time，原文另外保存以便核对
+
+var countRe = regexp.MustCompile(`(\d[\d,]*(?:\.\d+)?)\s*(万|亿|[wWkK])?`)
+
+// ParseChineseCount 解析 "1.2万"、"3,024"、"2亿"、"1.5k" 这样的计数，
+// 文本中可以带有其它文字，如 "阅读 1.2万"，只取第一个数字
+func ParseChineseCount(s string) (int, error) {
+	m := countRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("%q 中没有数字", s)
+	}
+	f, err := strconv.ParseFloat(strings.Replace(m[1], ",", "", -1), 64)
+	if err != nil {
+		return 0, err
+	}
+	switch m[2] {
+	case "万", "w", "W":
+		f *= 1e4
+	case "亿":
+		f *= 1e8
+	case "k", "K":
+		f *= 1e3
+	}
+	return int(math.Round(f)), nil
+}
+
+// parseCount 与 ParseChineseCount 相同，解析失败时返回 0
+func parseCount(s string) int {
+	n, _ := ParseChineseCount(s)
+	return n
+}
+
+// setCount 同时记下计数的原文和解析结果
+func setCount(n *int, raw *string, s string) {
+	*raw = strings.TrimSpace(s)
+	*n = parseCount(*raw)
+}
+
+// setTime 同时记下时间的原文和解析结果，无法解析时时间保持零值
+func setTime(t *time.Time, raw *string, s string) {
+	*raw = strings.TrimSpace(s)
+	if *raw == "" {
+		return
+	}
+	if v, err := ParseChineseTime(*raw, time.Now()); err == nil {
+		*t = v
+	}
+}
+
+var (
+	relativeRe = regexp.MustCompile(`^(\d+)\s*(秒|分钟|小时|天|周|个月|月|年)前$`)
+	dayRe      = regexp.MustCompile(`^(今天|昨天|前天)\s*(\d{1,2}):(\d{2})$`)
+	monthDayRe = regexp.MustCompile(`^(?:(\d{4})[年.\-/])?(\d{1,2})[月.\-/](\d{1,2})日?(?:\s+(\d{1,2}):(\d{2})(?::(\d{2}))?)?$`)
+)
+
+// ParseChineseTime 解析简书上出现的各种时间写法，相对时间以 now 为基准，结果为北京时间。支持：
+//
+//	2018-03-20T10:12:00+08:00   2018.03.20 10:12   2018-03-20 10:12:33   2018年03月20日 10:12
+//	03.20 10:12   3月20日   刚刚   5分钟前   3天前   昨天 10:12   1518415920（unix 时间戳）
+func ParseChineseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now = now.In(chinaTime)
+
+	for _, layout := range []string{time.RFC3339Nano, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if len(s) == 10 || len(s) == 13 {
+		if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if len(s) == 13 {
+				return time.Unix(ts/1000, ts%1000*int64(time.Millisecond)).In(chinaTime), nil
+			}
+			return time.Unix(ts, 0).In(chinaTime), nil
+		}
+	}
+
+	if s == "刚刚" {
+		return now, nil
+	}
+	if m := relativeRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "秒":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "分钟":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "小时":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "天":
+			return now.AddDate(0, 0, -n), nil
+		case "周":
+			return now.AddDate(0, 0, -7*n), nil
+		case "个月", "月":
+			return now.AddDate(0, -n, 0), nil
+		case "年":
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+	if m := dayRe.FindStringSubmatch(s); m != nil {
+		days := map[string]int{"今天": 0, "昨天": 1, "前天": 2}[m[1]]
+		hour, _ := strconv.Atoi(m[2])
+		min, _ := strconv.Atoi(m[3])
+		d := now.AddDate(0, 0, -days)
+		return time.Date(d.Year(), d.Month(), d.Day(), hour, min, 0, 0, chinaTime), nil
+	}
+	if m := monthDayRe.FindStringSubmatch(s); m != nil {
+		year := now.Year()
+		if m[1] != "" {
+			year, _ = strconv.Atoi(m[1])
+		}
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		hour, _ := strconv.Atoi(m[4])
+		min, _ := strconv.Atoi(m[5])
+		sec, _ := strconv.Atoi(m[6])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return time.Time{}, fmt.Errorf("无法识别的时间 %q", s)
+		}
+		t := time.Date(year, time.Month(month), day, hour, min, sec, 0, chinaTime)
+		//没有年份的日期如果比现在还晚，说明是去年的
+		if m[1] == "" && t.After(now) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间 %q", s)
+}
@@ -0,0 +1,62 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChineseCount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"12", 12},
+		{"阅读 3,024", 3024},
+		{"1.2万", 12000},
+		{" 10万 ", 100000},
+		{"2.35亿", 235000000},
+		{"1.5k", 1500},
+		{"字数 2345", 2345},
+	}
+	for _, tt := range tests {
+		got, err := ParseChineseCount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseChineseCount(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseChineseCount("喜欢"); err == nil {
+		t.Error("expected error for text without digits")
+	}
+}
+
+func TestParseChineseTime(t *testing.T) {
+	now := time.Date(2018, 3, 20, 15, 30, 0, 0, chinaTime)
+	at := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, chinaTime)
+	}
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2018-03-20T10:12:00+08:00", at(2018, 3, 20, 10, 12)},
+		{"2018.03.20 10:12", at(2018, 3, 20, 10, 12)},
+		{"2017-12-01", at(2017, 12, 1, 0, 0)},
+		{"2016年5月4日 08:00", at(2016, 5, 4, 8, 0)},
+		{"03.19 22:05", at(2018, 3, 19, 22, 5)},
+		{"12月31日", at(2017, 12, 31, 0, 0)},
+		{"刚刚", now},
+		{"5分钟前", at(2018, 3, 20, 15, 25)},
+		{"3天前", at(2018, 3, 17, 15, 30)},
+		{"昨天 09:01", at(2018, 3, 19, 9, 1)},
+		{"1521512400", at(2018, 3, 20, 10, 20)},
+	}
+	for _, tt := range tests {
+		got, err := ParseChineseTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseChineseTime(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseChineseTime("很久以前", now); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
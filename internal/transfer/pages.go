@@ -0,0 +1,45 @@
+package transfer
+
+import (
+	"github.com/xiye518/crawjianshu/internal/http"
+)
+
+// maxListPages 防止站点分页异常时无限翻页
+const maxListPages = 1000
+
+// crawlArticlePages 从第 2 页开始依次请求 pageURL(page) 并解析文章，
+// 直到某一页没有任何新文章为止；first 是已经解析好的第 1 页
+func crawlArticlePages(httpClient *http.Client, first []*Article, pageURL func(page int) string) ([]*Article, error) {
+	seen := make(map[string]bool)
+	arts := make([]*Article, 0, len(first))
+	add := func(list []*Article) (added int) {
+		for _, a := range list {
+			key := canonicalURL(a.Url)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			arts = append(arts, a)
+			added++
+		}
+		return added
+	}
+
+	if add(first) == 0 {
+		return arts, nil
+	}
+	for page := 2; page <= maxListPages; page++ {
+		body, err := fetchListPage(httpClient, pageURL(page))
+		if err != nil {
+			return arts, err
+		}
+		list, err := ParseArticles(body)
+		if err != nil {
+			return arts, err
+		}
+		if add(list) == 0 {
+			break
+		}
+	}
+	return arts, nil
+}
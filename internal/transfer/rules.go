@@ -0,0 +1,330 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+)
+
+// 抽取规则可以写在 json 配置文件里，站点改版时改配置即可，不必重新编译。格式见 rules/jianshu.json：
+//
+//	{
+//	  "name": "jianshu", "hosts": ["jianshu.com"], "home": "https://www.jianshu.com/",
+//	  "list": {
+//	    "item": "ul.note-list > li",
+//	    "fields": {
+//	      "title": {"selector": "a.title", "transforms": ["trim"]},
+//	      "url":   {"selector": "a.title", "attr": "href", "transforms": ["absolute"]}
+//	    }
+//	  },
+//	  "detail": {"fields": {"body": {"selector": ".show-content", "attr": "html"}}}
+//	}
+//
+// 每个字段依次执行：用 selector 选出节点（为空时就是列表项本身或整个页面）→ 取 attr
+// （为空取文本，"html" 取内部html）→ 用 regex 取第一个分组 → 按顺序执行 transforms
+
+// FieldRule 是一个字段的抽取规则
+type FieldRule struct {
+	Selector   string   `json:"selector"`
+	Attr       string   `json:"attr"`
+	Regex      string   `json:"regex"`
+	All        bool     `json:"all"` //取全部匹配的节点，用于 tags 这类多值字段
+	Transforms []string `json:"transforms"`
+
+	sel selector
+	re  *regexp.Regexp
+}
+
+// ListRules 是列表页的规则，Item 选出每一篇文章所在的节点
+type ListRules struct {
+	Item   string                `json:"item"`
+	Fields map[string]*FieldRule `json:"fields"`
+
+	item selector
+}
+
+// DetailRules 是文章页的规则
+type DetailRules struct {
+	Fields map[string]*FieldRule `json:"fields"`
+}
+
+// RuleSite 是从配置文件加载的站点规则，实现了 SiteAdapter
+type RuleSite struct {
+	SiteName string       `json:"name"`
+	Hosts    []string     `json:"hosts"`
+	Home     string       `json:"home"`
+	List     *ListRules   `json:"list"`
+	Detail   *DetailRules `json:"detail"`
+
+	base *http.URL
+}
+
+// 列表规则可用的字段名，与 Article 的字段一一对应
+var listFields = map[string]bool{
+	"note_id": true, "title": true, "author": true, "abstract": true, "url": true,
+	"publish_time": true, "watched": true, "comment": true, "collection": true,
+}
+
+// 文章规则在列表字段之外还可以使用这些字段
+var detailFields = map[string]bool{
+	"slug": true, "author_slug": true, "word_count": true, "likes": true, "rewards": true,
+	"tags": true, "body": true,
+}
+
+var transforms = map[string]bool{"trim": true, "number": true, "absolute": true, "lower": true}
+
+// LoadRules 读取并检查一个站点规则文件
+func LoadRules(path string) (*RuleSite, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(b)
+}
+
+// ParseRules 解析并检查 json 格式的站点规则
+func ParseRules(b []byte) (*RuleSite, error) {
+	var rs RuleSite
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, err
+	}
+	if rs.SiteName == "" || len(rs.Hosts) == 0 {
+		return nil, fmt.Errorf("规则缺少 name 或 hosts")
+	}
+	if rs.Home != "" {
+		u, err := http.Parse(rs.Home)
+		if err != nil {
+			return nil, err
+		}
+		rs.base = u
+	}
+
+	if rs.List != nil {
+		var err error
+		if rs.List.item, err = compileSelector(rs.List.Item); err != nil {
+			return nil, fmt.Errorf("list.item: %s", err)
+		}
+		if err := compileFields(rs.List.Fields, listFields, nil); err != nil {
+			return nil, fmt.Errorf("list: %s", err)
+		}
+	}
+	if rs.Detail != nil {
+		if err := compileFields(rs.Detail.Fields, listFields, detailFields); err != nil {
+			return nil, fmt.Errorf("detail: %s", err)
+		}
+	}
+	return &rs, nil
+}
+
+func compileFields(fields map[string]*FieldRule, allowed, extra map[string]bool) (err error) {
+	for name, f := range fields {
+		if !allowed[name] && !extra[name] {
+			return fmt.Errorf("未知字段 %s", name)
+		}
+		if f.Selector != "" {
+			if f.sel, err = compileSelector(f.Selector); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		if f.Regex != "" {
+			if f.re, err = regexp.Compile(f.Regex); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		for _, t := range f.Transforms {
+			if !transforms[t] {
+				return fmt.Errorf("%s: 未知的 transform %s", name, t)
+			}
+		}
+	}
+	return nil
+}
+
+func (rs *RuleSite) Name() string { return rs.SiteName }
+
+func (rs *RuleSite) HomeURL() string { return rs.Home }
+
+func (rs *RuleSite) Match(u *http.URL) bool {
+	for _, h := range rs.Hosts {
+		if hostMatches(u.Host, strings.ToLower(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseList 按 list 规则解析列表页，没有 url 的条目会被丢弃
+func (rs *RuleSite) ParseList(body string) ([]*Article, error) {
+	arts := make([]*Article, 0)
+	if rs.List == nil {
+		return arts, fmt.Errorf("站点 %s 没有配置 list 规则", rs.SiteName)
+	}
+	doc, err := parseHTML(body)
+	if err != nil {
+		return arts, err
+	}
+	for _, item := range rs.List.item.selectAll(doc) {
+		var a Article
+		for name, f := range rs.List.Fields {
+			setArticleField(&a, name, rs.value(f, item))
+		}
+		if a.Url != "" {
+			arts = append(arts, &a)
+		}
+	}
+	return arts, nil
+}
+
+// ParseDetail 按 detail 规则解析文章页
+func (rs *RuleSite) ParseDetail(body string) (*ArticleDetail, error) {
+	if rs.Detail == nil {
+		return nil, fmt.Errorf("站点 %s 没有配置 detail 规则", rs.SiteName)
+	}
+	doc, err := parseHTML(body)
+	if err != nil {
+		return nil, err
+	}
+	var d ArticleDetail
+	for name, f := range rs.Detail.Fields {
+		switch name {
+		case "tags":
+			d.Tags = rs.values(f, doc)
+		case "slug":
+			d.Slug = rs.value(f, doc)
+		case "author_slug":
+			d.AuthorSlug = rs.value(f, doc)
+		case "word_count":
+			d.WordCount = parseCount(rs.value(f, doc))
+		case "likes":
+			//与内置的解析一致：文章页上的“喜欢”就是列表页上的 Collection
+			setCount(&d.Collection, &d.CollectionRaw, rs.value(f, doc))
+		case "rewards":
+			d.Rewards = parseCount(rs.value(f, doc))
+		case "body":
+			d.BodyHtml = strings.TrimSpace(rs.value(f, doc))
+		default:
+			setArticleField(&d.Article, name, rs.value(f, doc))
+		}
+	}
+	if d.BodyHtml == "" {
+		return nil, ErrNoArticleBody
+	}
+	d.Likes = d.Collection
+	if body, err := parseHTML(d.BodyHtml); err == nil {
+		d.BodyText = plainText(body)
+	}
+	if d.Abstract == "" {
+		d.Abstract = abstractOf(d.BodyText)
+	}
+	return &d, nil
+}
+
+func setArticleField(a *Article, name, v string) {
+	switch name {
+	case "note_id":
+		a.NoteId = v
+	case "title":
+		a.Title = v
+	case "author":
+		a.AUthor = v
+	case "abstract":
+		a.Abstract = v
+	case "url":
+		a.Url = v
+	case "publish_time":
+		setTime(&a.PublishTime, &a.PublishTimeRaw, v)
+	case "watched":
+		setCount(&a.Watched, &a.WatchedRaw, v)
+	case "comment":
+		setCount(&a.Comment, &a.CommentRaw, v)
+	case "collection":
+		setCount(&a.Collection, &a.CollectionRaw, v)
+	}
+}
+
+// value 在 scope 下按规则取一个值
+func (rs *RuleSite) value(f *FieldRule, scope *html.Node) string {
+	n := scope
+	if f.sel != nil {
+		if n = f.sel.selectFirst(scope); n == nil {
+			return ""
+		}
+	}
+	return rs.apply(f, n)
+}
+
+// values 在 scope 下按规则取全部非空值
+func (rs *RuleSite) values(f *FieldRule, scope *html.Node) []string {
+	nodes := []*html.Node{scope}
+	if f.sel != nil {
+		nodes = f.sel.selectAll(scope)
+	}
+	if !f.All && len(nodes) > 1 {
+		nodes = nodes[:1]
+	}
+	var vs []string
+	for _, n := range nodes {
+		if v := rs.apply(f, n); v != "" {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+func (rs *RuleSite) apply(f *FieldRule, n *html.Node) string {
+	var v string
+	switch f.Attr {
+	case "":
+		v = text(n)
+	case "html":
+		v = innerHTML(n)
+	default:
+		v = attr(n, f.Attr)
+	}
+
+	if f.re != nil {
+		m := f.re.FindStringSubmatch(v)
+		switch {
+		case m == nil:
+			v = ""
+		case len(m) > 1:
+			v = m[1]
+		default:
+			v = m[0]
+		}
+	}
+
+	for _, t := range f.Transforms {
+		switch t {
+		case "trim":
+			v = strings.TrimSpace(collapseSpace(v))
+		case "number":
+			v = strconv.Itoa(parseCount(v))
+		case "lower":
+			v = strings.ToLower(v)
+		case "absolute":
+			v = rs.absolute(v)
+		}
+	}
+	return v
+}
+
+func (rs *RuleSite) absolute(ref string) string {
+	if ref == "" || rs.base == nil {
+		return ref
+	}
+	if strings.HasPrefix(ref, "//") {
+		ref = rs.base.Scheme + ":" + ref
+	}
+	u, err := http.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return rs.base.ResolveReference(u).String()
+}
@@ -0,0 +1,60 @@
+package transfer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleSiteMatchesBuiltinParser(t *testing.T) {
+	rs, err := LoadRules("../../rules/jianshu.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseArticles(homeFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rs.ParseList(homeFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d articles, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("article %d:\ngot  %+v\nwant %+v", i, *got[i], *want[i])
+		}
+	}
+}
+
+func TestCompileSelector(t *testing.T) {
+	for _, s := range []string{"a.title", "ul.note-list > li[data-note-id]", "div .meta a[href^=/u/], #x"} {
+		if _, err := compileSelector(s); err != nil {
+			t.Errorf("%q: %s", s, err)
+		}
+	}
+	for _, s := range []string{"", "> a", "a >", "a[href"} {
+		if _, err := compileSelector(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestRuleSiteDetailMatchesBuiltinParser(t *testing.T) {
+	rs, err := LoadRules("../../rules/jianshu.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseArticleDetail(detailFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rs.ParseDetail(detailFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("detail rules:\ngot  %+v\nwant %+v", *got, *want)
+	}
+}
@@ -0,0 +1,249 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"golang.org/x/net/html"
+)
+
+// SearchType 是站内搜索的结果类型，取值即 /search/do 的 type 参数
+type SearchType string
+
+const (
+	SearchNote       SearchType = "note"
+	SearchUser       SearchType = "user"
+	SearchCollection SearchType = "collection"
+)
+
+// Searcher 封装站内搜索。搜索接口是 POST /search/do，
+// 需要先打开搜索页取得 csrf-token 和会话 cookie，之后的请求带上 X-CSRF-Token 头
+type Searcher struct {
+	// MaxPages 每个关键词最多读取的页数，0 表示读到最后一页
+	MaxPages int
+
+	httpClient *http.Client
+	csrfToken  string
+}
+
+func NewSearcher(httpClient *http.Client) *Searcher {
+	return &Searcher{httpClient: httpClient}
+}
+
+// 搜索接口的返回，entries 的结构随 type 不同而不同
+type searchPage struct {
+	Page       int               `json:"page"`
+	TotalPages int               `json:"total_pages"`
+	TotalCount int               `json:"total_count"`
+	Entries    []json.RawMessage `json:"entries"`
+}
+
+type searchUserJSON struct {
+	Slug     string `json:"slug"`
+	Nickname string `json:"nickname"`
+}
+
+type searchNoteJSON struct {
+	Id                  int64          `json:"id"`
+	Title               string         `json:"title"`
+	Slug                string         `json:"slug"`
+	Content             string         `json:"content"`
+	User                searchUserJSON `json:"user"`
+	ViewsCount          int            `json:"views_count"`
+	LikesCount          int            `json:"likes_count"`
+	PublicCommentsCount int            `json:"public_comments_count"`
+	FirstSharedAt       string         `json:"first_shared_at"`
+}
+
+type searchAuthorJSON struct {
+	Slug             string `json:"slug"`
+	Nickname         string `json:"nickname"`
+	FollowersCount   int    `json:"followers_count"`
+	FollowingCount   int    `json:"following_count"`
+	PublicNotesCount int    `json:"public_notes_count"`
+	TotalWordage     int    `json:"total_wordage"`
+	TotalLikesCount  int    `json:"total_likes_count"`
+}
+
+type searchCollectionJSON struct {
+	Slug             string         `json:"slug"`
+	Title            string         `json:"title"`
+	PublicNotesCount int            `json:"public_notes_count"`
+	SubscribersCount int            `json:"subscribers_count"`
+	Owner            searchUserJSON `json:"owner"`
+}
+
+// article、user、collection 把搜索结果转换为对应的类型，去掉用于高亮关键词的标签
+func (e *searchNoteJSON) article() *Article {
+	a := &Article{
+		NoteId:        strconv.FormatInt(e.Id, 10),
+		Title:         stripTags(e.Title),
+		AUthor:        e.User.Nickname,
+		Abstract:      stripTags(e.Content),
+		Url:           "/p/" + e.Slug,
+		Watched:       e.ViewsCount,
+		Comment:       e.PublicCommentsCount,
+		Collection:    e.LikesCount,
+		WatchedRaw:    strconv.Itoa(e.ViewsCount),
+		CommentRaw:    strconv.Itoa(e.PublicCommentsCount),
+		CollectionRaw: strconv.Itoa(e.LikesCount),
+	}
+	setTime(&a.PublishTime, &a.PublishTimeRaw, e.FirstSharedAt)
+	return a
+}
+
+func (e *searchAuthorJSON) user() *User {
+	return &User{
+		Slug:      e.Slug,
+		Nickname:  stripTags(e.Nickname),
+		Following: e.FollowingCount,
+		Followers: e.FollowersCount,
+		Articles:  e.PublicNotesCount,
+		Words:     e.TotalWordage,
+		Likes:     e.TotalLikesCount,
+	}
+}
+
+func (e *searchCollectionJSON) collection() *Collection {
+	return &Collection{
+		Id:        e.Slug,
+		Title:     stripTags(e.Title),
+		Owner:     e.Owner.Nickname,
+		OwnerSlug: e.Owner.Slug,
+		Count:     e.PublicNotesCount,
+		Followers: e.SubscribersCount,
+	}
+}
+
+// Articles 搜索文章，每得到一条结果调用一次 fn，fn 返回 false 时停止
+func (s *Searcher) Articles(keyword string, fn func(*Article) bool) error {
+	return s.search(keyword, SearchNote, func(raw json.RawMessage) (bool, error) {
+		var e searchNoteJSON
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return false, err
+		}
+		return fn(e.article()), nil
+	})
+}
+
+// Users 搜索作者，得到的 User 不含文章列表
+func (s *Searcher) Users(keyword string, fn func(*User) bool) error {
+	return s.search(keyword, SearchUser, func(raw json.RawMessage) (bool, error) {
+		var e searchAuthorJSON
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return false, err
+		}
+		return fn(e.user()), nil
+	})
+}
+
+// Collections 搜索专题，得到的 Collection 不含文章列表
+func (s *Searcher) Collections(keyword string, fn func(*Collection) bool) error {
+	return s.search(keyword, SearchCollection, func(raw json.RawMessage) (bool, error) {
+		var e searchCollectionJSON
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return false, err
+		}
+		return fn(e.collection()), nil
+	})
+}
+
+// ArticlesForKeywords 依次搜索多个关键词，同一篇文章只回调一次
+func (s *Searcher) ArticlesForKeywords(keywords []string, fn func(keyword string, a *Article) bool) error {
+	seen := make(map[string]bool)
+	for _, kw := range keywords {
+		stop := false
+		err := s.Articles(kw, func(a *Article) bool {
+			key := canonicalURL(a.Url)
+			if seen[key] {
+				return true
+			}
+			seen[key] = true
+			if !fn(kw, a) {
+				stop = true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("搜索 %s: %s", kw, err)
+		}
+		if stop {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Searcher) search(keyword string, typ SearchType, entry func(json.RawMessage) (bool, error)) error {
+	if s.csrfToken == "" {
+		if err := s.prepare(keyword, typ); err != nil {
+			return err
+		}
+	}
+
+	for page := 1; s.MaxPages == 0 || page <= s.MaxPages; page++ {
+		req := newJSONRequest(http.MethodPost, JianShuHost+"/search/do").
+			SetHeader(`X-CSRF-Token`, s.csrfToken).
+			SetHeader(`Origin`, JianShuHost).
+			SetHeader(`Referer`, searchPageURL(keyword, typ)).
+			AddParam("q", keyword).
+			AddParam("type", string(typ)).
+			AddParam("page", strconv.Itoa(page)).
+			AddParam("order_by", "default")
+
+		var sp searchPage
+		if err := fetchJSON(s.httpClient, req, &sp); err != nil {
+			return err
+		}
+		for _, raw := range sp.Entries {
+			goon, err := entry(raw)
+			if err != nil {
+				return err
+			}
+			if !goon {
+				return nil
+			}
+		}
+		if len(sp.Entries) == 0 || page >= sp.TotalPages || page >= maxListPages {
+			break
+		}
+	}
+	return nil
+}
+
+// prepare 打开搜索页，取得 csrf-token，同时让 cookie jar 记下会话 cookie
+func (s *Searcher) prepare(keyword string, typ SearchType) error {
+	body, err := fetchPage(s.httpClient, searchPageURL(keyword, typ))
+	if err != nil {
+		return err
+	}
+	doc, err := parseHTML(body)
+	if err != nil {
+		return err
+	}
+	meta := findFirst(doc, func(n *html.Node) bool {
+		return isElement(n, "meta") && attr(n, "name") == "csrf-token"
+	})
+	if meta == nil || attr(meta, "content") == "" {
+		return fmt.Errorf("搜索页中没有找到 csrf-token")
+	}
+	s.csrfToken = attr(meta, "content")
+	return nil
+}
+
+func searchPageURL(keyword string, typ SearchType) string {
+	return JianShuHost + "/search?q=" + http.QueryEscape(keyword) + "&page=1&type=" + string(typ)
+}
+
+// stripTags 去掉搜索结果中用于高亮关键词的 <em> 等标签。
+// 不用 text：它在文本节点之间加空格，“三个<em>思维</em>模型”会变成“三个 思维 模型”
+func stripTags(s string) string {
+	doc, err := parseHTML(s)
+	if err != nil {
+		return s
+	}
+	return collapseSpace(plainText(doc))
+}
@@ -0,0 +1,50 @@
+package transfer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const searchFixture = `{"type":"note","page":1,"total_pages":3,"total_count":25,"entries":[
+{"id":26393040,"title":"大脑版本升级：练习三个<em class='search-result-highlight'>思维</em>模型","slug":"6603d0ad230f",
+ "content":"一下午就能让你聪明起来","user":{"slug":"4a4eb4feee62","nickname":"采铜"},
+ "views_count":12000,"likes_count":120,"public_comments_count":12,"first_shared_at":"2018-03-20T10:12:00.000+08:00"}
+]}`
+
+func TestSearchEntries(t *testing.T) {
+	var sp searchPage
+	if err := json.Unmarshal([]byte(searchFixture), &sp); err != nil {
+		t.Fatal(err)
+	}
+	if sp.TotalPages != 3 || len(sp.Entries) != 1 {
+		t.Fatalf("page = %+v", sp)
+	}
+	var e searchNoteJSON
+	if err := json.Unmarshal(sp.Entries[0], &e); err != nil {
+		t.Fatal(err)
+	}
+	a := e.article()
+	published := time.Date(2018, 3, 20, 10, 12, 0, 0, chinaTime)
+	if a.Title != "大脑版本升级：练习三个思维模型" || a.Url != "/p/6603d0ad230f" || a.NoteId != "26393040" || a.AUthor != "采铜" {
+		t.Errorf("article = %+v", *a)
+	}
+	if a.Watched != 12000 || a.Comment != 12 || a.Collection != 120 || !a.PublishTime.Equal(published) {
+		t.Errorf("counts = %d %d %d, time %v", a.Watched, a.Comment, a.Collection, a.PublishTime)
+	}
+
+	var u searchAuthorJSON
+	if err := json.Unmarshal([]byte(`{"slug":"4a4eb4feee62","nickname":"<em>采</em>铜","followers_count":12000,"public_notes_count":38}`), &u); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.user(); got.Nickname != "采铜" || got.Followers != 12000 || got.Articles != 38 {
+		t.Errorf("user = %+v", *got)
+	}
+	var c searchCollectionJSON
+	if err := json.Unmarshal([]byte(`{"slug":"V2CqjW","title":"<em>读书</em>","public_notes_count":1234,"owner":{"slug":"9e5e9ab7b2ad","nickname":"简书编辑"}}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.collection(); got.Id != "V2CqjW" || got.Title != "读书" || got.Count != 1234 || got.OwnerSlug != "9e5e9ab7b2ad" {
+		t.Errorf("collection = %+v", *got)
+	}
+}
@@ -0,0 +1,183 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Segmenter 是基于词典的中文分词。对每个句子按词频求概率最大的切分（与 jieba 不启用 HMM 时相同），
+// 词典外连续的单字（2~4 个）合并为一个词，英文单词和数字各自作为一个词
+type Segmenter struct {
+	freq   map[string]int
+	total  float64
+	maxLen int
+	extra  map[string]int //AddWord、LoadDict 在内置词典之外加入的词
+}
+
+// NewSegmenter 用内置的小词典创建分词器，需要更好的效果时用 LoadDict 加载完整词典
+func NewSegmenter() *Segmenter {
+	s := &Segmenter{freq: make(map[string]int), extra: make(map[string]int)}
+	for _, w := range strings.Fields(builtinWords) {
+		s.add(w, 100)
+	}
+	return s
+}
+
+// AddWord 向词典加入一个词，freq 越大越倾向于切出这个词
+func (s *Segmenter) AddWord(word string, freq int) {
+	if freq <= 0 {
+		freq = 1
+	}
+	s.extra[word] = freq
+	s.add(word, freq)
+}
+
+// ExtraWords 返回在内置词典之外加入的词及词频，对新的 Segmenter 逐个 AddWord 可以得到相同的分词结果
+func (s *Segmenter) ExtraWords() map[string]int {
+	words := make(map[string]int, len(s.extra))
+	for w, f := range s.extra {
+		words[w] = f
+	}
+	return words
+}
+
+func (s *Segmenter) add(word string, freq int) {
+	s.total += float64(freq - s.freq[word])
+	s.freq[word] = freq
+	if n := len([]rune(word)); n > s.maxLen {
+		s.maxLen = n
+	}
+}
+
+// LoadDict 加载词典文件，每行为“词 词频 [词性]”，与 jieba 的 dict.txt 格式相同，词频可省略
+func (s *Segmenter) LoadDict(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		freq := 100
+		if len(fields) > 1 {
+			if freq, err = strconv.Atoi(fields[1]); err != nil {
+				return fmt.Errorf("%s:%d: 词频 %q 不是整数", file, line, fields[1])
+			}
+		}
+		s.AddWord(fields[0], freq)
+	}
+	return sc.Err()
+}
+
+// Cut 把文本切分为词，空白和标点不输出
+func (s *Segmenter) Cut(text string) []string {
+	var words []string
+	var han []rune
+	flush := func() {
+		if len(han) > 0 {
+			words = append(words, s.cutHan(han)...)
+			han = han[:0]
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han = append(han, r)
+			i++
+		case isWordRune(r):
+			flush()
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			words = append(words, strings.ToLower(string(runes[i:j])))
+			i = j
+		default:
+			flush()
+			i++
+		}
+	}
+	flush()
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '_'
+}
+
+// cutHan 对一段连续的汉字求最大概率切分
+func (s *Segmenter) cutHan(runes []rune) []string {
+	n := len(runes)
+	logTotal := math.Log(s.total + 1)
+	//best[i] 是 runes[i:] 的最大对数概率，next[i] 是从 i 开始的词的结尾
+	best := make([]float64, n+1)
+	next := make([]int, n+1)
+	for i := n - 1; i >= 0; i-- {
+		best[i] = math.Inf(-1)
+		for j := i + 1; j <= n && (j == i+1 || j-i <= s.maxLen); j++ {
+			f, ok := s.freq[string(runes[i:j])]
+			if !ok {
+				if j > i+1 {
+					continue
+				}
+				f = 1
+			}
+			if p := math.Log(float64(f)) - logTotal + best[j]; p > best[i] {
+				best[i], next[i] = p, j
+			}
+		}
+	}
+
+	var words []string
+	var single []rune
+	flush := func() {
+		if len(single) >= 2 && len(single) <= 4 {
+			words = append(words, string(single))
+		} else {
+			for _, r := range single {
+				words = append(words, string(r))
+			}
+		}
+		single = single[:0]
+	}
+	for i := 0; i < n; i = next[i] {
+		w := runes[i:next[i]]
+		if len(w) == 1 {
+			if _, ok := s.freq[string(w)]; !ok {
+				single = append(single, w[0])
+				continue
+			}
+		}
+		flush()
+		words = append(words, string(w))
+	}
+	flush()
+	return words
+}
+
+// builtinWords 是内置的常用词，只够应付简书上常见的话题，正式使用时请用 LoadDict 加载完整词典
+const builtinWords = `
+的 了 是 在 我 你 他 她 它 们 这 那 有 和 与 就 也 都 而 及 着 给 被 把 让 从 对 到 说 要 会 能 很 还 又 没 不 人 个 上 下 中 大 小 多 少 好
+我们 你们 他们 她们 它们 自己 大家 别人 什么 怎么 怎样 为什么 这个 那个 这些 那些 这样 那样 这里 那里 哪里
+一个 一些 一样 一直 一定 一起 一种 一天 一年 一次 一下 已经 曾经 正在 现在 以前 以后 后来 然后 之后 之前 时候 今天 明天 昨天
+因为 所以 但是 可是 不过 如果 虽然 而且 或者 还是 就是 只是 于是 然而 并且 不是 没有 可以 应该 需要 知道 觉得 认为 希望 喜欢 开始 发现 成为 看到 听到 想到
+生活 工作 学习 时间 世界 社会 朋友 父母 孩子 家庭 爱情 婚姻 青春 人生 故事 梦想 未来 过去 城市 家乡 旅行 读书 写作 文章 作者 读者 文字 小说 诗歌 电影 音乐
+简书 专题 文集 创作 分享 经验 方法 技巧 问题 能力 思维 习惯 效率 成长 改变 坚持 努力 成功 失败 选择 目标 计划 心理 情绪 焦虑 健康 运动 减肥 美食
+编程 程序 程序员 代码 开发 技术 算法 数据 互联网 产品 设计 运营 用户 公司 职场 创业 投资 理财 经济 金钱 教育 学校 老师 学生 考试 大学 英语
+中国 北京 上海 历史 文化 哲学 科学 艺术 自然 手机 电脑 软件 工具 笔记 日记 记录 思考 感受 温暖 孤独 幸福 快乐
+`
@@ -0,0 +1,221 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 一个够用的 CSS 选择器子集，供配置文件中的抽取规则使用。支持：
+//
+//	tag  .class  #id  [attr]  [attr=v]  [attr^=v]  [attr$=v]  [attr*=v]  [attr~=v]
+//	后代 "a b"、子元素 "a > b"、并列 "a, b"
+type selector [][]selStep
+
+type selStep struct {
+	combinator byte //与前一步的关系：' ' 后代，'>' 子元素；第一步为 0
+	tag        string
+	id         string
+	classes    []string
+	attrs      []attrSel
+}
+
+type attrSel struct {
+	key, val string
+	op       string //"" 表示只要求属性存在
+}
+
+func compileSelector(s string) (selector, error) {
+	var sel selector
+	for _, group := range strings.Split(s, ",") {
+		steps, err := compileSteps(strings.TrimSpace(group))
+		if err != nil {
+			return nil, fmt.Errorf("选择器 %q: %s", s, err)
+		}
+		sel = append(sel, steps)
+	}
+	return sel, nil
+}
+
+func compileSteps(s string) ([]selStep, error) {
+	if s == "" {
+		return nil, fmt.Errorf("为空")
+	}
+	var steps []selStep
+	comb := byte(0)
+	for s != "" {
+		switch s[0] {
+		case ' ', '\t', '\n':
+			if comb == 0 && len(steps) > 0 {
+				comb = ' '
+			}
+			s = s[1:]
+			continue
+		case '>':
+			if len(steps) == 0 {
+				return nil, fmt.Errorf("不能以 > 开头")
+			}
+			comb = '>'
+			s = s[1:]
+			continue
+		}
+
+		step := selStep{combinator: comb}
+		comb = 0
+		n, err := parseCompound(s, &step)
+		if err != nil {
+			return nil, err
+		}
+		s = s[n:]
+		steps = append(steps, step)
+	}
+	if comb == '>' {
+		return nil, fmt.Errorf("不能以 > 结尾")
+	}
+	return steps, nil
+}
+
+// parseCompound 解析一个不含空白的简单选择器，如 a.title[target=_blank]，返回消耗的字节数
+func parseCompound(s string, step *selStep) (int, error) {
+	i := 0
+	ident := func() string {
+		start := i
+		for i < len(s) && !strings.ContainsRune(" \t\n>.#[,", rune(s[i])) {
+			i++
+		}
+		return s[start:i]
+	}
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else if i < len(s) && !strings.ContainsRune(".#[", rune(s[i])) {
+		step.tag = strings.ToLower(ident())
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			i++
+			step.classes = append(step.classes, ident())
+		case '#':
+			i++
+			step.id = ident()
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return 0, fmt.Errorf("缺少 ]")
+			}
+			step.attrs = append(step.attrs, parseAttrSel(s[i+1:i+end]))
+			i += end + 1
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+func parseAttrSel(s string) attrSel {
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		return attrSel{key: strings.TrimSpace(s)}
+	}
+	a := attrSel{op: "="}
+	key := s[:eq]
+	if eq > 0 && strings.ContainsRune("^$*~", rune(s[eq-1])) {
+		a.op = s[eq-1 : eq+1]
+		key = s[:eq-1]
+	}
+	a.key = strings.TrimSpace(key)
+	a.val = strings.Trim(strings.TrimSpace(s[eq+1:]), `"'`)
+	return a
+}
+
+func (st *selStep) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if st.tag != "" && n.Data != st.tag {
+		return false
+	}
+	if st.id != "" && attr(n, "id") != st.id {
+		return false
+	}
+	for _, c := range st.classes {
+		if !hasClass(n, c) {
+			return false
+		}
+	}
+	for _, a := range st.attrs {
+		v, ok := "", false
+		for _, na := range n.Attr {
+			if na.Key == a.key {
+				v, ok = na.Val, true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+		switch a.op {
+		case "=":
+			ok = v == a.val
+		case "^=":
+			ok = strings.HasPrefix(v, a.val)
+		case "$=":
+			ok = strings.HasSuffix(v, a.val)
+		case "*=":
+			ok = strings.Contains(v, a.val)
+		case "~=":
+			ok = false
+			for _, f := range strings.Fields(v) {
+				if f == a.val {
+					ok = true
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSteps 判断 n 是否满足 steps[:i+1]，root 以外的祖先不参与匹配
+func matchSteps(steps []selStep, i int, n, root *html.Node) bool {
+	if !steps[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch steps[i].combinator {
+	case '>':
+		p := n.Parent
+		return p != nil && p != root && matchSteps(steps, i-1, p, root)
+	default:
+		for p := n.Parent; p != nil && p != root; p = p.Parent {
+			if matchSteps(steps, i-1, p, root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (sel selector) match(n, root *html.Node) bool {
+	for _, steps := range sel {
+		if matchSteps(steps, len(steps)-1, n, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAll 返回 root 下满足选择器的全部节点
+func (sel selector) selectAll(root *html.Node) []*html.Node {
+	return findAll(root, func(n *html.Node) bool { return sel.match(n, root) })
+}
+
+// selectFirst 返回 root 下第一个满足选择器的节点
+func (sel selector) selectFirst(root *html.Node) *html.Node {
+	return findFirst(root, func(n *html.Node) bool { return sel.match(n, root) })
+}
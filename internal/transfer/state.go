@@ -0,0 +1,382 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// 新版简书页面（Next.js）把页面数据以 json 形式放在 <script id="__NEXT_DATA__"> 中，
+// 旧版页面则有 <script data-name="page-data"> 或 window.__INITIAL_STATE__ = {...}。
+// 这些数据比html结构稳定得多，解析时优先使用
+
+var ErrNoState = errors.New("页面中没有内嵌的 json 数据")
+
+// chinaTime 简书上的时间都是北京时间
+var chinaTime = time.FixedZone("CST", 8*3600)
+
+// NextData 是 __NEXT_DATA__ 中我们关心的部分
+type NextData struct {
+	Page  string `json:"page"`
+	Props struct {
+		InitialState struct {
+			Note struct {
+				Data *NextNote `json:"data"`
+			} `json:"note"`
+		} `json:"initialState"`
+		PageProps json.RawMessage `json:"pageProps"`
+	} `json:"props"`
+}
+
+// NextNote 是 __NEXT_DATA__ 中的一篇文章
+type NextNote struct {
+	Id                 int64  `json:"id"`
+	Slug               string `json:"slug"`
+	Title              string `json:"title"`
+	PublicTitle        string `json:"public_title"`
+	Content            string `json:"content"`
+	FreeContent        string `json:"free_content"`
+	Wordage            int    `json:"wordage"`
+	ViewsCount         int    `json:"views_count"`
+	LikesCount         int    `json:"likes_count"`
+	CommentsCount      int    `json:"comments_count"`
+	PublicCommentCount int    `json:"public_comment_count"`
+	TotalRewardsCount  int    `json:"total_rewards_count"`
+	FirstSharedAt      int64  `json:"first_shared_at"` //unix 时间戳
+	LastUpdatedAt      int64  `json:"last_updated_at"`
+	User               struct {
+		Slug     string `json:"slug"`
+		Nickname string `json:"nickname"`
+	} `json:"user"`
+}
+
+// Note 返回 __NEXT_DATA__ 中的文章，依次查找 initialState.note.data 和 pageProps.note
+func (nd *NextData) Note() *NextNote {
+	if n := nd.Props.InitialState.Note.Data; n != nil && n.Slug != "" {
+		return n
+	}
+	if len(nd.Props.PageProps) > 0 {
+		var pp struct {
+			Note *NextNote `json:"note"`
+		}
+		if json.Unmarshal(nd.Props.PageProps, &pp) == nil && pp.Note != nil && pp.Note.Slug != "" {
+			return pp.Note
+		}
+	}
+	return nil
+}
+
+// PageData 是旧版页面 <script data-name="page-data"> 中的数据
+type PageData struct {
+	Note struct {
+		Id                int64  `json:"id"`
+		Slug              string `json:"slug"`
+		ViewsCount        int    `json:"views_count"`
+		LikesCount        int    `json:"likes_count"`
+		CommentsCount     int    `json:"comments_count"`
+		TotalRewardsCount int    `json:"total_rewards_count"`
+		Author            struct {
+			Slug     string `json:"slug"`
+			Nickname string `json:"nickname"`
+		} `json:"author"`
+	} `json:"note"`
+}
+
+// ParseState 找到页面中的第一段内嵌 json 数据并解码到 v，v 通常是 *NextData 或 *PageData
+func ParseState(body string, v interface{}) error {
+	doc, err := parseHTML(body)
+	if err != nil {
+		return err
+	}
+	blobs := stateBlobs(doc)
+	if len(blobs) == 0 {
+		return ErrNoState
+	}
+	return json.Unmarshal(blobs[0], v)
+}
+
+// stateBlobs 按 __NEXT_DATA__、page-data、__INITIAL_STATE__ 的顺序返回页面中所有内嵌的 json
+func stateBlobs(doc *html.Node) [][]byte {
+	var next, pageData, initial [][]byte
+	for _, s := range findAll(doc, byTag("script")) {
+		src := strings.TrimSpace(rawText(s))
+		if src == "" {
+			continue
+		}
+		switch {
+		case attr(s, "id") == "__NEXT_DATA__":
+			next = append(next, []byte(src))
+		case attr(s, "data-name") == "page-data":
+			pageData = append(pageData, []byte(src))
+		case strings.Contains(src, "__INITIAL_STATE__"):
+			if b := assignedJSON(src, "__INITIAL_STATE__"); b != nil {
+				initial = append(initial, b)
+			}
+		}
+	}
+	return append(append(next, pageData...), initial...)
+}
+
+// assignedJSON 取出脚本中 name = {...} 赋值语句右边的 json 对象
+func assignedJSON(src, name string) []byte {
+	i := strings.Index(src, name)
+	if i < 0 {
+		return nil
+	}
+	rest := src[i+len(name):]
+	j := strings.Index(rest, "{")
+	if j < 0 || strings.TrimSpace(rest[:j]) != "=" {
+		return nil
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(rest[j:])).Decode(&raw); err != nil {
+		return nil
+	}
+	return raw
+}
+
+func nextDataOf(doc *html.Node) *NextData {
+	s := findFirst(doc, func(n *html.Node) bool {
+		return isElement(n, "script") && attr(n, "id") == "__NEXT_DATA__"
+	})
+	if s == nil {
+		return nil
+	}
+	var nd NextData
+	if err := json.Unmarshal([]byte(rawText(s)), &nd); err != nil {
+		return nil
+	}
+	return &nd
+}
+
+func pageDataOf(doc *html.Node) *PageData {
+	s := findFirst(doc, func(n *html.Node) bool {
+		return isElement(n, "script") && attr(n, "data-name") == "page-data"
+	})
+	if s == nil {
+		return nil
+	}
+	var pd PageData
+	if err := json.Unmarshal([]byte(rawText(s)), &pd); err != nil || pd.Note.Id == 0 {
+		return nil
+	}
+	return &pd
+}
+
+// detailFromState 从 __NEXT_DATA__ 中构造文章详情，数据不完整时返回 nil
+func detailFromState(doc *html.Node) *ArticleDetail {
+	nd := nextDataOf(doc)
+	if nd == nil {
+		return nil
+	}
+	n := nd.Note()
+	if n == nil {
+		return nil
+	}
+	content := n.Content
+	if content == "" {
+		content = n.FreeContent
+	}
+	if content == "" {
+		return nil
+	}
+
+	var d ArticleDetail
+	d.NoteId = strconv.FormatInt(n.Id, 10)
+	d.Slug = n.Slug
+	d.Url = "/p/" + n.Slug
+	d.Title = n.PublicTitle
+	if d.Title == "" {
+		d.Title = n.Title
+	}
+	d.AUthor = n.User.Nickname
+	d.AuthorSlug = n.User.Slug
+	if n.FirstSharedAt > 0 {
+		d.PublishTime = time.Unix(n.FirstSharedAt, 0).In(chinaTime)
+		d.PublishTimeRaw = strconv.FormatInt(n.FirstSharedAt, 10)
+	}
+	d.WordCount = n.Wordage
+	d.Watched = n.ViewsCount
+	d.WatchedRaw = strconv.Itoa(n.ViewsCount)
+	d.Comment = n.PublicCommentCount
+	if d.Comment == 0 {
+		d.Comment = n.CommentsCount
+	}
+	d.CommentRaw = strconv.Itoa(d.Comment)
+	d.Likes = n.LikesCount
+	d.Collection = n.LikesCount
+	d.CollectionRaw = strconv.Itoa(n.LikesCount)
+	d.Rewards = n.TotalRewardsCount
+
+	d.BodyHtml = strings.TrimSpace(content)
+	if body, err := parseHTML(content); err == nil {
+		d.BodyText = plainText(body)
+	}
+	d.Abstract = abstractOf(d.BodyText)
+	return &d
+}
+
+// StateNote 是列表页内嵌数据中的一篇文章。各版本页面的字段名不完全相同，这里取它们的并集
+type StateNote struct {
+	Id                  int64           `json:"id"`
+	Slug                string          `json:"slug"`
+	Title               string          `json:"title"`
+	PublicTitle         string          `json:"public_title"`
+	Abstract            string          `json:"abstract"`
+	Desc                string          `json:"desc"`
+	ViewsCount          int             `json:"views_count"`
+	LikesCount          int             `json:"likes_count"`
+	CommentsCount       int             `json:"comments_count"`
+	PublicCommentsCount int             `json:"public_comments_count"`
+	FirstSharedAt       json.RawMessage `json:"first_shared_at"` //unix 时间戳或时间字符串
+	User                *stateUser      `json:"user"`
+	Author              *stateUser      `json:"author"`
+}
+
+type stateUser struct {
+	Slug     string `json:"slug"`
+	Nickname string `json:"nickname"`
+}
+
+// noteKeys 是文章特有的字段，用来把文章和同样有 slug、title 的专题区分开
+var noteKeys = []string{"views_count", "likes_count", "first_shared_at", "public_title", "wordage"}
+
+// stateNotes 按出现的顺序返回内嵌数据中所有数组里的文章，同一篇文章只返回一次
+func stateNotes(blobs [][]byte) []*StateNote {
+	var notes []*StateNote
+	seen := make(map[string]bool)
+	for _, b := range blobs {
+		walkState(b, false, func(raw json.RawMessage) bool {
+			var keys map[string]json.RawMessage
+			if json.Unmarshal(raw, &keys) != nil || !isNoteObject(keys) {
+				return false
+			}
+			var n StateNote
+			if json.Unmarshal(raw, &n) != nil {
+				return false
+			}
+			if !seen[n.Slug] {
+				seen[n.Slug] = true
+				notes = append(notes, &n)
+			}
+			return true
+		})
+	}
+	return notes
+}
+
+func isNoteObject(keys map[string]json.RawMessage) bool {
+	var slug, title, publicTitle string
+	json.Unmarshal(keys["slug"], &slug)
+	json.Unmarshal(keys["title"], &title)
+	json.Unmarshal(keys["public_title"], &publicTitle)
+	if slug == "" || title == "" && publicTitle == "" {
+		return false
+	}
+	for _, k := range noteKeys {
+		if _, ok := keys[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkState 按原来的顺序遍历 json，数组中的对象（包括嵌套在数组元素里的，如 {"object":{"data":{...}}}）
+// 先交给 note，note 返回 false 时再深入其中。数组之外的单个对象是详情页的文章，不算列表。
+// 对象按键的顺序逐个解码，不经过 map，所以结果的顺序与页面一致
+func walkState(raw []byte, inArray bool, note func(json.RawMessage) bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return
+	}
+	switch raw[0] {
+	case '[':
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return
+		}
+		for _, it := range items {
+			walkState(it, true, note)
+		}
+	case '{':
+		if inArray && note(raw) {
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if _, err := dec.Token(); err != nil {
+			return
+		}
+		for dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return
+			}
+			var v json.RawMessage
+			if dec.Decode(&v) != nil {
+				return
+			}
+			walkState(v, inArray, note)
+		}
+	}
+}
+
+// Article 把内嵌数据中的文章转换为 Article
+func (n *StateNote) Article() *Article {
+	a := &Article{
+		Title:    n.PublicTitle,
+		Abstract: n.Abstract,
+		Url:      "/p/" + n.Slug,
+		Watched:  n.ViewsCount,
+		Comment:  n.PublicCommentsCount,
+	}
+	if n.Id != 0 {
+		a.NoteId = strconv.FormatInt(n.Id, 10)
+	}
+	if a.Title == "" {
+		a.Title = n.Title
+	}
+	if a.Abstract == "" {
+		a.Abstract = n.Desc
+	}
+	a.Abstract = strings.TrimSpace(a.Abstract)
+	if u := n.User; u != nil {
+		a.AUthor = u.Nickname
+	} else if u := n.Author; u != nil {
+		a.AUthor = u.Nickname
+	}
+	if a.Comment == 0 {
+		a.Comment = n.CommentsCount
+	}
+	a.Collection = n.LikesCount
+	a.WatchedRaw = strconv.Itoa(a.Watched)
+	a.CommentRaw = strconv.Itoa(a.Comment)
+	a.CollectionRaw = strconv.Itoa(a.Collection)
+
+	var unix int64
+	var s string
+	switch {
+	case json.Unmarshal(n.FirstSharedAt, &unix) == nil && unix > 0:
+		a.PublishTime = time.Unix(unix, 0).In(chinaTime)
+		a.PublishTimeRaw = strconv.FormatInt(unix, 10)
+	case json.Unmarshal(n.FirstSharedAt, &s) == nil:
+		setTime(&a.PublishTime, &a.PublishTimeRaw, s)
+	}
+	return a
+}
+
+// articlesFromState 从列表页的内嵌数据中取出文章，没有时返回 nil
+func articlesFromState(doc *html.Node) []*Article {
+	blobs := stateBlobs(doc)
+	if len(blobs) == 0 {
+		return nil
+	}
+	var arts []*Article
+	for _, n := range stateNotes(blobs) {
+		arts = append(arts, n.Article())
+	}
+	return arts
+}
@@ -0,0 +1,71 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+)
+
+var stateFixtures = []struct {
+	name, body string
+}{
+	{"next data", `<html><body><ul class="note-list"><li><a class="title" href="/p/html">html</a></li></ul>
+<script id="__NEXT_DATA__" type="application/json">{"page":"/","props":{"pageProps":{"banners":[{"id":1,"title":"广告","slug":"ad"}],
+"notes":[{"id":26393040,"slug":"6603d0ad230f","public_title":"大脑版本升级","abstract":" 一下午 ","views_count":12000,
+"likes_count":120,"public_comments_count":12,"first_shared_at":1521511920,"user":{"slug":"4a4eb4feee62","nickname":"采铜"}},
+{"id":2,"slug":"bbbbbbbbbbbb","title":"第二篇","views_count":1}]}}}</script></body></html>`},
+	{"page data", `<html><body><script type="application/json" data-name="page-data">{"user_id":1,
+"list":[{"object":{"data":{"id":26393040,"slug":"6603d0ad230f","title":"大脑版本升级","desc":"一下午","views_count":12000,
+"likes_count":120,"comments_count":12,"first_shared_at":"2018-03-20T10:12:00+08:00","author":{"slug":"4a4eb4feee62","nickname":"采铜"}}}},
+{"object":{"data":{"id":2,"slug":"bbbbbbbbbbbb","title":"第二篇","views_count":1}}}]}</script></body></html>`},
+	{"initial state", `<html><body><script>window.__INITIAL_STATE__ = {"timeline":{"notes":[
+{"id":26393040,"slug":"6603d0ad230f","title":"大脑版本升级","abstract":"一下午","views_count":12000,"likes_count":120,
+"public_comments_count":12,"first_shared_at":1521511920,"user":{"nickname":"采铜"}},
+{"id":26393040,"slug":"6603d0ad230f","title":"重复","views_count":0},
+{"id":2,"slug":"bbbbbbbbbbbb","title":"第二篇","views_count":1}]}};window.x = 1;</script></body></html>`},
+}
+
+func TestParseArticlesState(t *testing.T) {
+	published := time.Date(2018, 3, 20, 10, 12, 0, 0, chinaTime)
+	for _, tt := range stateFixtures {
+		arts, err := ParseArticles(tt.body)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if len(arts) != 2 {
+			t.Errorf("%s: got %d articles, want 2", tt.name, len(arts))
+			continue
+		}
+		a := arts[0]
+		if a.NoteId != "26393040" || a.Url != "/p/6603d0ad230f" || a.Title != "大脑版本升级" || a.Abstract != "一下午" || a.AUthor != "采铜" {
+			t.Errorf("%s: got %+v", tt.name, *a)
+		}
+		if a.Watched != 12000 || a.Comment != 12 || a.Collection != 120 || !a.PublishTime.Equal(published) {
+			t.Errorf("%s: counts %d %d %d, time %v", tt.name, a.Watched, a.Comment, a.Collection, a.PublishTime)
+		}
+		if arts[1].Url != "/p/bbbbbbbbbbbb" {
+			t.Errorf("%s: second article = %+v", tt.name, *arts[1])
+		}
+	}
+}
+
+func TestParseArticlesStateFallback(t *testing.T) {
+	//内嵌数据里只有文章详情，没有文章列表，退回到html
+	body := `<html><body><script type="application/json" data-name="page-data">{"note":{"id":1,"slug":"x","title":"详情","views_count":1}}</script>
+<ul class="note-list"><li data-note-id="9"><div class="content"><a class="title" href="/p/html">html</a><p class="abstract">摘要</p></div></li></ul></body></html>`
+	arts, err := ParseArticles(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arts) != 1 || arts[0].Url != "/p/html" || arts[0].NoteId != "9" {
+		t.Errorf("got %+v, want the html list", arts)
+	}
+
+	var pd PageData
+	if err := ParseState(body, &pd); err != nil || pd.Note.Id != 1 {
+		t.Errorf("ParseState = %v, note id %d", err, pd.Note.Id)
+	}
+	if err := ParseState(homeFixture, &pd); err != ErrNoState {
+		t.Errorf("ParseState without state: err = %v, want ErrNoState", err)
+	}
+}
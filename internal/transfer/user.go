@@ -0,0 +1,90 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xiye518/crawjianshu/internal/http"
+	"github.com/xiye518/crawjianshu/internal/tools/console/color"
+)
+
+// User 是作者主页（/u/<slug>）上的信息
+type User struct {
+	Slug      string
+	Nickname  string
+	Following int //关注
+	Followers int //粉丝
+	Articles  int //文章数
+	Words     int //字数
+	Likes     int //收获喜欢
+
+	ArticleList []*Article
+}
+
+// ParseUser 解析作者主页的html，ArticleList 中只包含第一页的文章
+func ParseUser(body string) (*User, error) {
+	doc, err := parseHTML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var u User
+	top := findFirst(doc, byClass("main-top"))
+	if top == nil {
+		return nil, fmt.Errorf("作者主页中没有找到 main-top")
+	}
+	if name := findFirst(top, byClass("name")); name != nil {
+		u.Nickname = text(name)
+		u.Slug = slugOf(attr(name, "href"), "/u/")
+	}
+
+	//每个 meta-block 是 <p>数字</p> 加上一个标签，如“粉丝”“字数”
+	for _, block := range findAll(top, byClass("meta-block")) {
+		n := parseCount(text(findFirst(block, byTag("p"))))
+		label := text(block)
+		switch {
+		case strings.Contains(label, "关注"):
+			u.Following = n
+		case strings.Contains(label, "粉丝"):
+			u.Followers = n
+		case strings.Contains(label, "文章"):
+			u.Articles = n
+		case strings.Contains(label, "字数"):
+			u.Words = n
+		case strings.Contains(label, "喜欢"):
+			u.Likes = n
+		}
+	}
+
+	u.ArticleList = ownArticles(doc)
+	return &u, nil
+}
+
+// FetchUser 抓取作者主页，并沿着作者的文章列表一直翻页到最后一页
+func FetchUser(httpClient *http.Client, slug string) (*User, error) {
+	if s := slugOf(slug, "/u/"); s != "" {
+		slug = s
+	}
+	url := JianShuHost + "/u/" + slug
+	body, err := fetchPage(httpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	u, err := ParseUser(body)
+	if err != nil {
+		return nil, err
+	}
+	if u.Slug == "" {
+		u.Slug = slug
+	}
+
+	u.ArticleList, err = crawlArticlePages(httpClient, u.ArticleList, func(page int) string {
+		return fmt.Sprintf("%s?order_by=shared_at&page=%d", url, page)
+	})
+	return u, err
+}
+
+func (u *User) String() {
+	color.LogAndPrintln(color.HiGreen(u.Nickname), JianShuHost+"/u/"+u.Slug,
+		"关注:", u.Following, "粉丝:", u.Followers, "文章:", u.Articles, "字数:", u.Words, "喜欢:", u.Likes)
+}
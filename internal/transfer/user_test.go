@@ -0,0 +1,66 @@
+package transfer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const userFixture = `<html><body>
+<div class="main-top">
+  <a class="avatar" href="/u/4a4eb4feee62"><img src="//upload.jianshu.io/users/1.jpg"></a>
+  <div class="title"><a class="name" href="/u/4a4eb4feee62">采铜</a></div>
+  <div class="info"><ul>
+    <li><div class="meta-block"><a href="/users/4a4eb4feee62/following"><p>25</p>关注 <i class="iconfont ic-arrow"></i></a></div></li>
+    <li><div class="meta-block"><a href="/users/4a4eb4feee62/followers"><p>1.2万</p>粉丝<i class="iconfont ic-arrow"></i></a></div></li>
+    <li><div class="meta-block"><a href="/u/4a4eb4feee62"><p>38</p>文章<i class="iconfont ic-arrow"></i></a></div></li>
+    <li><div class="meta-block"><p>125,303</p><div>字数</div></div></li>
+    <li><div class="meta-block"><p>5321</p><div>收获喜欢</div></div></li>
+  </ul></div>
+</div>
+<ul class="note-list">
+<li data-note-id="1"><div class="content"><a class="title" href="/p/aaaaaaaaaaaa">第一篇</a><p class="abstract">摘要一</p></div></li>
+<li data-note-id="2"><div class="content"><a class="title" href="/p/bbbbbbbbbbbb">第二篇</a><p class="abstract">摘要二</p></div></li>
+</ul>
+</body></html>`
+
+func TestParseUser(t *testing.T) {
+	u, err := ParseUser(userFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := User{Slug: "4a4eb4feee62", Nickname: "采铜", Following: 25, Followers: 12000, Articles: 38, Words: 125303, Likes: 5321}
+	got := *u
+	got.ArticleList = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if len(u.ArticleList) != 2 || u.ArticleList[1].Url != "/p/bbbbbbbbbbbb" || u.ArticleList[1].NoteId != "2" {
+		t.Errorf("article list = %+v", u.ArticleList)
+	}
+
+	if _, err := ParseUser(`<html><body></body></html>`); err == nil {
+		t.Error("page without main-top parsed without error")
+	}
+}
+
+func TestParseUserIgnoresOtherStateNotes(t *testing.T) {
+	//内嵌数据中还有推荐文章，列表以页面上的为准，只借用同一篇文章的数据
+	body := strings.Replace(userFixture, "</body>", `<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{
+"recommended_notes":[{"id":9,"slug":"zzzzzzzzzzzz","title":"推荐","views_count":99}],
+"notes":[{"id":1,"slug":"aaaaaaaaaaaa","title":"第一篇","views_count":300,"likes_count":12}]}}}</script></body>`, 1)
+	u, err := ParseUser(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, a := range u.ArticleList {
+		urls = append(urls, a.Url)
+	}
+	if want := []string{"/p/aaaaaaaaaaaa", "/p/bbbbbbbbbbbb"}; !reflect.DeepEqual(urls, want) {
+		t.Fatalf("article urls = %v, want %v", urls, want)
+	}
+	if a := u.ArticleList[0]; a.Watched != 300 || a.Collection != 12 || a.NoteId != "1" {
+		t.Errorf("first article = %+v, want the counts from the state", a)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
-	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/xiye518/crawjianshu/internal/crawl"
 	"github.com/xiye518/crawjianshu/internal/http"
 	"github.com/xiye518/crawjianshu/internal/tools/console/color"
 	"github.com/xiye518/crawjianshu/internal/transfer"
@@ -16,50 +19,140 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "train" || os.Args[1] == "eval") {
+		runClassifyCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "crawl" {
+		runCrawlCommand(os.Args[2:])
+		return
+	}
+
 	color.LogAndPrintln(color.HiCyan("this is a crawlJianshu test\n"))
-	httpClient := http.NewClient().DialTimeout(20 * time.Second)
+	httpClient := http.NewClient().DialTimeout(20 * time.Second).Retry(http.DefaultRetryPolicy())
 
-	//此处通过http请求获取到简书首页的文本html
-	body, err := searchJianShuHome(httpClient)
-	if err != nil {
-		log.Fatal(err)
+	//-rules 指定的规则文件优先于内置的解析规则，首页、列表页和文章页都按它解析，站点改版时改配置即可
+	rules := flag.String("rules", "", "站点抽取规则文件，如 rules/jianshu.json")
+	site := flag.String("site", transfer.JianShuHost+"/", "要抓取的站点，按地址选出解析规则，从该站点的首页开始")
+	analyze := flag.Bool("analyze", false, "统计每篇文章的字数、阅读时间和关键词")
+	model := flag.String("model", "", "分类模型文件，由 train 命令生成，指定后给每篇文章分类")
+	//调试解析规则时用 -cache 把页面缓存下来，之后加上 -offline 就不再访问网络
+	cacheDir := flag.String("cache", "", "http 缓存目录")
+	offline := flag.Bool("offline", false, "只从 -cache 指定的缓存读取页面")
+	robots := flag.Bool("robots", true, "遵守 robots.txt")
+	report := flag.String("robots-report", "", "把因 robots.txt 跳过的地址写入该文件")
+	flag.Parse()
+	useCache(httpClient, *cacheDir, *offline)
+	//首页、列表页、文章页、图片和搜索的请求都经过 httpClient，在这里统一检查 robots.txt
+	var robotsCache *crawl.RobotsCache
+	if *robots {
+		robotsCache = crawl.NewRobotsCache(httpClient, crawl.ProductToken)
+		httpClient.CheckRequest = robotsCache.CheckRequest
+	}
+	defer reportRobots(robotsCache, *report)
+	if *rules != "" {
+		rs, err := transfer.LoadRules(*rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		transfer.RegisterAdapter(rs)
 	}
 
-	//color.LogAndPrintln(color.HiGreen(body))
-	//ToDo  也可以用ioutil将文本写出
-	//此处为使用正则从文本中切取想要的内容
-	arts, err := transfer.ParseArticles(body)
+	//指定了列表页地址时，按地址所属站点的规则解析该页
+	if flag.NArg() > 0 {
+		arts, err := transfer.FetchList(httpClient, flag.Arg(0))
+		if err != nil {
+			reportRobots(robotsCache, *report)
+			log.Fatal(err)
+		}
+		for i, a := range arts {
+			a.String(i)
+		}
+		return
+	}
+
+	//此处通过http请求逐页获取站点首页的文章，并按站点的规则从html中解析出文章列表
+	adapter, err := transfer.AdapterFor(*site)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if adapter.HomeURL() == "" {
+		log.Fatalf("站点 %s 的规则没有配置首页地址", adapter.Name())
+	}
+	feed := transfer.NewSiteFeed(httpClient, adapter)
+	feed.MaxPages = 3
+	feed.Health = transfer.NewHealthCheck("data/health")
 
-	for i, a := range arts {
-		//color.LogAndPrintln(i, color.HiGreen(a.Title), "https://www.jianshu.com"+a.Url,a.Abstract)
-		a.String(i)
+	var analyzer *transfer.Analyzer
+	if *analyze {
+		analyzer = transfer.NewAnalyzer()
+	}
+	var classifier *transfer.Classifier
+	if *model != "" {
+		var err error
+		if classifier, err = transfer.LoadClassifier(*model); err != nil {
+			log.Fatal(err)
+		}
+	}
+	i := 0
+	for feed.Next() {
+		arts := feed.Articles()
+		if analyzer != nil {
+			analyzer.EnrichArticles(arts)
+		}
+		if classifier != nil {
+			classifier.ClassifyArticles(arts)
+		}
+		for _, a := range arts {
+			a.String(i)
+			if a.Category != "" {
+				color.LogAndPrintln("    分类:", a.Category, fmt.Sprintf("(%.2f)", a.CategoryScore))
+			}
+			if a.Stats != nil {
+				printStats(a.Stats)
+			}
+			i++
+		}
+	}
+	if err := feed.Err(); err != nil {
+		reportRobots(robotsCache, *report)
+		log.Fatal(err)
 	}
 }
 
-func searchJianShuHome(httpClient *http.Client) (body string, err error) {
-	resp, hcerr := http.NewRequest(http.MethodGet,
-		`https://www.jianshu.com/`).
-		SetHeader(`Accept`, `text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8`).
-		SetHeader(`Accept-Encoding`, `gzip, deflate, br`).
-		SetHeader(`Accept-Language`, `zh-CN,zh;q=0.9`).
-		SetHeader(`Connection`, `keep-alive`).
-		SetHeader(`Upgrade-Insecure-Requests`, `1`).
-		SetHeader(`User-Agent`, `Mozilla/5.0 (Windows NT 6.3; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/63.0.3239.132 Safari/537.36`).
-		SendBy(httpClient)
-	if hcerr != nil {
-		return body, errors.New(fmt.Sprintf("%s", hcerr))
-	}
-	defer resp.Body.Close()
-
-	bytes, err := resp.BodyBytes()
-	if err != nil {
-		return body, err
+// reportRobots 输出因 robots.txt 跳过的地址数，file 不为空时写出详细的列表
+func reportRobots(rc *crawl.RobotsCache, file string) {
+	if rc == nil {
+		return
+	}
+	skipped := rc.Skipped()
+	if len(skipped) > 0 {
+		color.LogAndPrintln("robots.txt 禁止:", len(skipped))
 	}
+	if file != "" {
+		if err := writeRobotsReport(file, skipped); err != nil {
+			log.Println(err)
+		}
+	}
+}
 
-	body = string(bytes)
+func printStats(st *transfer.TextStats) {
+	var kws []string
+	for _, k := range st.Keywords {
+		kws = append(kws, k.Word)
+	}
+	color.LogAndPrintln("    字数:", st.Chars, "阅读时间:", st.ReadingTime, "语言:", st.Script.Language,
+		"关键词:", strings.Join(kws, " "))
+}
 
-	return body, nil
+func useCache(httpClient *http.Client, dir string, offline bool) {
+	if dir == "" {
+		if offline {
+			log.Fatal("-offline 需要同时指定 -cache")
+		}
+		return
+	}
+	cache := http.NewCache(dir)
+	cache.Offline = offline
+	httpClient.UseCache(cache)
 }